@@ -0,0 +1,333 @@
+package sq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+type (
+	// AccessorFunc extracts the text a pipeline starts from.  arg
+	// is the text between the accessor's parentheses, ie. the name
+	// in attr(name).
+	AccessorFunc func(sel *goquery.Selection, arg string) (string, error)
+
+	accessorDef struct {
+		f AccessorFunc
+		// arity is 1 if the accessor requires an arg, 0 if it
+		// takes none and -1 if either is accepted.
+		arity int
+		// empty accessors are called when the selector
+		// matches nothing instead of reporting a missing node.
+		empty bool
+		// attrs returns the attribute map of the accessor,
+		// which map and struct fields are decoded from.
+		attrs func(sel *goquery.Selection) []html.Attribute
+	}
+
+	// accessor is the resolved first stage of a pipeline.
+	accessor struct {
+		name string
+		args string
+		accessorDef
+	}
+)
+
+var accessors = map[string]accessorDef{
+	"text": {arity: 0, f: func(sel *goquery.Selection, _ string) (string, error) {
+		return strings.TrimSpace(sel.Text()), nil
+	}},
+	"html": {arity: 0, f: func(sel *goquery.Selection, _ string) (string, error) {
+		s, err := sel.Html()
+		return strings.TrimSpace(s), err
+	}},
+	"outerhtml": {arity: 0, f: func(sel *goquery.Selection, _ string) (string, error) {
+		s, err := goquery.OuterHtml(sel)
+		return strings.TrimSpace(s), err
+	}},
+	"attr": {arity: 1, f: func(sel *goquery.Selection, name string) (string, error) {
+		s, exists := sel.Attr(name)
+		if !exists {
+			return "", fmt.Errorf("%w: attr(%s)", ErrAttributeNotFound, name)
+		}
+		return strings.TrimSpace(s), nil
+	}},
+	"owntext":   {arity: 0, f: ownText},
+	"innertext": {arity: 0, f: innerText},
+	"attrs":     {arity: 0, f: renderAttrs(attrs), attrs: attrs},
+	"dataset":   {arity: 0, f: renderAttrs(dataset), attrs: dataset},
+	"tag": {arity: 0, f: func(sel *goquery.Selection, _ string) (string, error) {
+		return goquery.NodeName(sel), nil
+	}},
+	"value": {arity: 0, f: formValue},
+	"count": {arity: 0, empty: true, f: func(sel *goquery.Selection, _ string) (string, error) {
+		return strconv.Itoa(sel.Length()), nil
+	}},
+}
+
+// pseudoFields are selectors that describe the current node rather
+// than select one.  Index and count are those of the node in its
+// collection.
+var pseudoFields = map[string]func(sel *goquery.Selection, pos position) string{
+	"index": func(_ *goquery.Selection, pos position) string {
+		return strconv.Itoa(pos.index)
+	},
+	"count": func(_ *goquery.Selection, pos position) string {
+		return strconv.Itoa(pos.count)
+	},
+	"tag": func(sel *goquery.Selection, _ position) string {
+		return goquery.NodeName(sel)
+	},
+	"path": func(sel *goquery.Selection, _ position) string {
+		return cssPath(sel)
+	},
+	"depth": func(sel *goquery.Selection, _ position) string {
+		return strconv.Itoa(sel.First().Parents().Length())
+	},
+}
+
+// cssPath returns a selector matching the first node of sel from the
+// document root, ie. html > body > table > tbody > tr:nth-child(2).
+func cssPath(sel *goquery.Selection) string {
+	if len(sel.Nodes) == 0 {
+		return ""
+	}
+	var parts []string
+	for n := sel.Nodes[0]; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		part := n.Data
+		if n.Parent != nil && n.Parent.Type == html.ElementNode {
+			i := 1
+			for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+				if s.Type == html.ElementNode {
+					i++
+				}
+			}
+			part += ":nth-child(" + strconv.Itoa(i) + ")"
+		}
+		parts = append([]string{part}, parts...)
+	}
+	return strings.Join(parts, " > ")
+}
+
+// RegisterAccessor registers an accessor on the default Scraper.
+func RegisterAccessor(name string, f AccessorFunc) {
+	defaultScraper.RegisterAccessor(name, f)
+}
+
+// RegisterAccessor adds or overrides the accessor called name.  The
+// accessor may be used with or without an arg.
+func (s *Scraper) RegisterAccessor(name string, f AccessorFunc) {
+	s.mu.Lock()
+	s.accessors[name] = accessorDef{f: f, arity: -1}
+	s.resetPlans()
+	s.mu.Unlock()
+}
+
+// accepts reports whether the accessor can be called with or,
+// unless hasArgs, without an arg.
+func (def accessorDef) accepts(hasArgs bool) bool {
+	switch def.arity {
+	case 0:
+		return !hasArgs
+	case 1:
+		return hasArgs
+	}
+	return true
+}
+
+// extract returns the text of sel.  A pipeline without an accessor
+// starts from the empty string.
+func (a *accessor) extract(sel *goquery.Selection) (string, error) {
+	if a == nil {
+		return "", nil
+	}
+	return a.f(sel, a.args)
+}
+
+// ownText returns the text nodes directly below the matched nodes,
+// ignoring the text of their descendants.
+func ownText(sel *goquery.Selection, _ string) (string, error) {
+	var b strings.Builder
+	for _, n := range sel.Nodes {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				b.WriteString(c.Data)
+			}
+		}
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// blocks are the elements innertext puts on their own lines.
+var blocks = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"caption": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"fieldset": true, "figcaption": true, "figure": true, "footer": true,
+	"form": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "header": true, "hr": true, "li": true,
+	"main": true, "nav": true, "ol": true, "p": true, "pre": true,
+	"section": true, "table": true, "tr": true, "ul": true,
+}
+
+// innerText renders the matched nodes roughly as a browser's
+// innerText does: whitespace is collapsed, block elements and <br>
+// start new lines, table cells are separated by tabs and scripts
+// and styles are skipped.
+func innerText(sel *goquery.Selection, _ string) (string, error) {
+
+	var (
+		b    strings.Builder
+		walk func(n *html.Node)
+	)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(strings.Map(func(r rune) rune {
+				if r == '\n' || r == '\t' || r == '\r' || r == '\f' {
+					return ' '
+				}
+				return r
+			}, n.Data))
+			return
+		case html.ElementNode:
+			switch n.Data {
+			case "script", "style", "template", "noscript":
+				return
+			case "br":
+				b.WriteByte('\n')
+				return
+			case "td", "th":
+				if prevCell(n) {
+					b.WriteByte('\t')
+				}
+			}
+		}
+		block := n.Type == html.ElementNode && blocks[n.Data]
+		if block {
+			b.WriteByte('\n')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			b.WriteByte('\n')
+		}
+	}
+	for _, n := range sel.Nodes {
+		walk(n)
+		b.WriteByte('\n')
+	}
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		cells := strings.Split(line, "\t")
+		for i, cell := range cells {
+			cells[i] = strings.Join(strings.Fields(cell), " ")
+		}
+		if line = strings.Join(cells, "\t"); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+
+}
+
+// prevCell reports whether the cell n follows another cell of its
+// row, skipping the text and comments between them.
+func prevCell(n *html.Node) bool {
+	for p := n.PrevSibling; p != nil; p = p.PrevSibling {
+		if p.Type == html.ElementNode {
+			return p.Data == "td" || p.Data == "th"
+		}
+	}
+	return false
+}
+
+// attrs returns the attributes of the first matched node.
+func attrs(sel *goquery.Selection) []html.Attribute {
+	if len(sel.Nodes) == 0 {
+		return nil
+	}
+	return sel.Nodes[0].Attr
+}
+
+// dataset returns the data-* attributes of the first matched node
+// named as in the DOM's dataset property, ie. data-foo-bar is fooBar.
+func dataset(sel *goquery.Selection) []html.Attribute {
+	var data []html.Attribute
+	for _, a := range attrs(sel) {
+		if !strings.HasPrefix(a.Key, "data-") {
+			continue
+		}
+		var (
+			b    strings.Builder
+			name = a.Key[len("data-"):]
+		)
+		for i := 0; i < len(name); i++ {
+			if name[i] == '-' && i+1 < len(name) && name[i+1] >= 'a' && name[i+1] <= 'z' {
+				i++
+				b.WriteByte(name[i] - 'a' + 'A')
+				continue
+			}
+			b.WriteByte(name[i])
+		}
+		data = append(data, html.Attribute{Key: b.String(), Val: a.Val})
+	}
+	return data
+}
+
+// renderAttrs renders an attribute map as it would appear in a
+// start tag, ie. `id="a" class="b c"`.
+func renderAttrs(attrs func(*goquery.Selection) []html.Attribute) AccessorFunc {
+	return func(sel *goquery.Selection, _ string) (string, error) {
+		as := attrs(sel)
+		parts := make([]string, len(as))
+		for i, a := range as {
+			parts[i] = a.Key + `="` + html.EscapeString(a.Val) + `"`
+		}
+		return strings.Join(parts, " "), nil
+	}
+}
+
+// formValue returns the current value of the first matched form
+// control: the selected option of a select, the text of a textarea
+// or the value attribute of anything else.  Checkboxes and radios
+// without a value are "on", as in browsers.
+func formValue(sel *goquery.Selection, _ string) (string, error) {
+	sel = sel.First()
+	switch goquery.NodeName(sel) {
+	case "textarea":
+		return strings.TrimSpace(sel.Text()), nil
+	case "select":
+		opt := sel.Find("option[selected]").First()
+		if opt.Length() == 0 {
+			opt = sel.Find("option").First()
+		}
+		if opt.Length() == 0 {
+			return "", nil
+		}
+		return formValue(opt, "")
+	case "option":
+		if s, exists := sel.Attr("value"); exists {
+			return strings.TrimSpace(s), nil
+		}
+		return strings.Join(strings.Fields(sel.Text()), " "), nil
+	case "input":
+		if s, exists := sel.Attr("value"); exists {
+			return strings.TrimSpace(s), nil
+		}
+		switch t, _ := sel.Attr("type"); strings.ToLower(t) {
+		case "checkbox", "radio":
+			return "on", nil
+		}
+		return "", nil
+	}
+	s, exists := sel.Attr("value")
+	if !exists {
+		return "", fmt.Errorf("%w: value", ErrAttributeNotFound)
+	}
+	return strings.TrimSpace(s), nil
+}
@@ -0,0 +1,113 @@
+package sq
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type (
+	// TagError describes an sq tag that cannot be compiled.
+	TagError struct {
+		// Field is the path to the field from the root
+		// struct, ie. Users[].Email
+		Field string
+		// Tag is the text of the sq tag
+		Tag string
+		// Stage is the part of the tag at fault, either
+		// "syntax", "selector", "accessor", "kind",
+		// "field", "option", "default", "loader",
+		// "required", the name of the option limit,
+		// offset, where, group, unique or in, or the
+		// name of a func.
+		Stage string
+		Err   error
+	}
+
+	// FieldError describes a field that could not be hydrated
+	// while scraping.
+	FieldError struct {
+		// Path is the path to the field from the root
+		// struct, ie. Users[3].Email
+		Path     string
+		Selector string
+		// Node is the path to the node that failed from
+		// the document root, ie. html > body > div:nth-child(2),
+		// or to the node searched if the selector matched
+		// nothing.  It is empty for the document itself.
+		Node string
+		// Stage is the step of the pipeline that failed,
+		// either "select", "accessor", "conversion" or the
+		// name of a parse func, load func or type loader.
+		Stage string
+		// Input is the text passed to the failing stage.
+		Input string
+		Err   error
+		// required is set for the errors of fields tagged
+		// required, whose elements skipinvalid drops.
+		required bool
+	}
+)
+
+func (e *TagError) Error() string {
+	if e.Field == "" {
+		return e.Err.Error()
+	}
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *TagError) Unwrap() error {
+	return e.Err
+}
+
+func newFieldError(field string, vp *valuePlan, sel *goquery.Selection, stage, input string, err error) *FieldError {
+	return &FieldError{
+		Path:     field,
+		Selector: vp.path.selector,
+		Node:     cssPath(sel),
+		Stage:    stage,
+		Input:    input,
+		Err:      err,
+	}
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s: (%s) %v", e.Path, e.Selector, e.Stage, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// hasRequiredErrors reports whether errs has a FieldError of a
+// required field.
+func hasRequiredErrors(errs []error) bool {
+	for _, err := range errs {
+		if fe, ok := err.(*FieldError); ok && (fe.required || errors.Is(fe, ErrRequired)) {
+			return true
+		}
+	}
+	return false
+}
+
+// markRequired marks the FieldErrors in errs as errors of a
+// required field.
+func markRequired(errs []error) {
+	for _, err := range errs {
+		if fe, ok := err.(*FieldError); ok {
+			fe.required = true
+		}
+	}
+}
+
+// tagErrors returns the TagErrors in errs.
+func tagErrors(errs []error) []error {
+	var tagErrs []error
+	for _, err := range errs {
+		if _, ok := err.(*TagError); ok {
+			tagErrs = append(tagErrs, err)
+		}
+	}
+	return tagErrs
+}
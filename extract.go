@@ -3,105 +3,595 @@ package sq
 import (
 	"fmt"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
 )
 
 type (
 	path struct {
+		tag      string
 		selector string
+		// query is the compiled selector.
+		query query
+		// acc is the accessor as written, ie. attr(id)
 		acc      string
-		parsers  []parser
-		loader   *loader
+		accessor *accessor
+		// pseudo is set by a pseudo-field selector,
+		// ie. @index, in place of an accessor.
+		pseudo  func(sel *goquery.Selection, pos position) string
+		parsers []parser
+		loader  *loader
+		// splitter splits the text into several values,
+		// which run the each parsers.
+		splitter *splitter
+		each     []parser
+		// typeLoader names the type loader chosen
+		// by the loader() option.
+		typeLoader *TypeLoader
+		// key and value are the pipelines run on
+		// each entry of a map field.
+		key, value *path
+		// optional values are zeroed, or set to def
+		// when present, if their node or attribute is
+		// missing.
+		optional bool
+		required bool
+		def      *string
+		// alts are the pipelines tried, in order,
+		// if this one fails.
+		alts []*path
+		// collection filters the elements of a
+		// slice field.
+		collection *collection
+		// location is set by in() and holds the
+		// times the time loader reads without a zone.
+		location *time.Location
 	}
-)
 
-const (
-	accessorAttr = "attr"
-	accessorHTML = "html"
-	accessorText = "text"
+	// collection holds the options choosing which
+	// elements of a slice field are kept.
+	collection struct {
+		// limit is the maximum number of elements,
+		// 0 is unlimited.
+		limit  int
+		offset int
+		// where keeps the nodes it selects from.
+		where query
+		// skipInvalid drops elements with field errors.
+		skipInvalid bool
+		// unique names the field elements are deduplicated
+		// by, or "" for the element itself.
+		unique *string
+		// group splits the children of the selected
+		// nodes into elements starting at each node
+		// it matches.
+		group goquery.Matcher
+	}
 )
 
-func extractString(sel *goquery.Selection, acc string) (string, error) {
+// query selects nodes relative to sel.  Queries are compiled from
+// selectors once, with the tag they belong to.
+type query func(sel *goquery.Selection) *goquery.Selection
 
+// compileQuery compiles selector into a query resolving it relative
+// to a selection.  A selector matching the selection itself, or
+// ".", selects it.  A leading "+" or "~" selects the adjacent or
+// following siblings of the selection that match the rest of the
+// selector.  Each node of a selection of several, ie. a group, is
+// resolved separately.  A leading "/" resolves the rest of the
+// selector from the document root, and a leading axis call, ie.
+// closest(table), resolves it from the nodes the axis selects.
+// Selectors starting with "xpath:" are XPath expressions.
+func compileQuery(selector string) (query, error) {
 	switch {
-	case acc == accessorHTML:
-		s, err := sel.Html()
-		return strings.TrimSpace(s), err
-	case acc == accessorText:
-		return strings.TrimSpace(sel.Text()), nil
-	case strings.HasPrefix(acc, accessorAttr):
-		s, exists := sel.Attr(trimAccessor(acc, accessorAttr))
-		if !exists {
-			return "", fmt.Errorf("%s: %v", ErrAttributeNotFound, acc)
+	case selector == "" || selector == ".":
+		return func(sel *goquery.Selection) *goquery.Selection { return sel }, nil
+	case strings.HasPrefix(selector, xpathPrefix):
+		e, err := compileXPath(selector)
+		if err != nil {
+			return nil, err
+		}
+		return func(sel *goquery.Selection) *goquery.Selection { return selectXPath(sel, e) }, nil
+	case strings.HasPrefix(selector, "/"):
+		rest, err := compileQuery(strings.TrimSpace(selector[1:]))
+		if err != nil {
+			return nil, err
+		}
+		return func(sel *goquery.Selection) *goquery.Selection { return rest(root(sel)) }, nil
+	case strings.HasPrefix(selector, "+"):
+		m, err := compileCSS(strings.TrimSpace(selector[1:]))
+		if err != nil {
+			return nil, err
+		}
+		return func(sel *goquery.Selection) *goquery.Selection { return sel.Next().FilterMatcher(m) }, nil
+	case strings.HasPrefix(selector, "~"):
+		m, err := compileCSS(strings.TrimSpace(selector[1:]))
+		if err != nil {
+			return nil, err
+		}
+		return func(sel *goquery.Selection) *goquery.Selection { return sel.NextAllMatcher(m) }, nil
+	}
+	if axis, arg, rest, ok := cutAxis(selector); ok {
+		aq, err := axis(arg)
+		if err != nil {
+			return nil, err
+		}
+		if rest == "" {
+			return aq, nil
+		}
+		rq, err := compileQuery(rest)
+		if err != nil {
+			return nil, err
+		}
+		return func(sel *goquery.Selection) *goquery.Selection {
+			if sel = aq(sel); sel.Length() == 0 {
+				return sel
+			}
+			return rq(sel)
+		}, nil
+	}
+	m, err := compileCSS(selector)
+	if err != nil {
+		return nil, err
+	}
+	find := func(sel *goquery.Selection) *goquery.Selection {
+		if sel.IsMatcher(m) {
+			return sel
+		}
+		return sel.FindMatcher(m)
+	}
+	return func(sel *goquery.Selection) *goquery.Selection {
+		if sel.Length() <= 1 {
+			return find(sel)
 		}
-		return strings.TrimSpace(s), nil
-	// jank
-	case acc == "":
-		return "", nil
+		// each node of a group matches
+		// itself or its descendants
+		var nodes []*html.Node
+		sel.Each(func(_ int, n *goquery.Selection) {
+			nodes = append(nodes, find(n).Nodes...)
+		})
+		return sel.FindNodes().AddNodes(nodes...)
+	}, nil
+}
+
+// compileCSS compiles a css selector after rewriting its text
+// pseudo-classes.
+func compileCSS(selector string) (goquery.Matcher, error) {
+	rewritten, err := rewritePseudoClasses(selector)
+	if err != nil {
+		return nil, err
 	}
+	m, err := cascadia.Compile(rewritten)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", ErrInvalidSelector, selector, err)
+	}
+	return m, nil
+}
 
-	return "", fmt.Errorf("Bad accessor: %q", acc)
+const xpathPrefix = "xpath:"
 
+// compileXPath compiles the expression of an xpath: selector,
+// which may be quoted to protect a top level "|" or ",".
+func compileXPath(selector string) (*xpath.Expr, error) {
+	expr := strings.TrimSpace(strings.TrimPrefix(selector, xpathPrefix))
+	if len(expr) > 1 && (expr[0] == '"' || expr[0] == '\'') && expr[len(expr)-1] == expr[0] {
+		expr = unquote(expr)
+	}
+	e, err := xpath.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidXPath, err)
+	}
+	return e, nil
 }
 
-func trimAccessor(s, prefix string) string {
-	s = strings.TrimPrefix(s, prefix)
-	s = strings.TrimPrefix(s, "(")
-	s = strings.TrimSuffix(s, ")")
-	return strings.TrimSpace(s)
+// selectXPath evaluates e against each node of sel.  Attributes
+// are selected as elements containing their value.
+func selectXPath(sel *goquery.Selection, e *xpath.Expr) *goquery.Selection {
+	var nodes []*html.Node
+	for _, n := range sel.Nodes {
+		nodes = append(nodes, htmlquery.QuerySelectorAll(n, e)...)
+	}
+	return sel.FindNodes().AddNodes(nodes...)
 }
 
-func parseFunctionSignature(s string) (string, string) {
+// root returns the document node sel belongs to.
+func root(sel *goquery.Selection) *goquery.Selection {
+	if sel.Length() == 0 {
+		return sel
+	}
+	n := sel.Nodes[0]
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	return goquery.NewDocumentFromNode(n).Selection
+}
 
-	var name, args string
+// axes select nodes around sel rather than below it.  The arg of
+// an axis filters the nodes it selects, except for the until axes
+// which take the selector to stop at and an optional filter.  Axes
+// compile their arg into a query.
+var axes = map[string]func(arg string) (query, error){
+	"closest":   filteredAxis(nil, (*goquery.Selection).ClosestMatcher),
+	"parent":    filteredAxis((*goquery.Selection).Parent, (*goquery.Selection).ParentMatcher),
+	"next":      filteredAxis((*goquery.Selection).Next, (*goquery.Selection).NextMatcher),
+	"prev":      filteredAxis((*goquery.Selection).Prev, (*goquery.Selection).PrevMatcher),
+	"nextall":   filteredAxis((*goquery.Selection).NextAll, (*goquery.Selection).NextAllMatcher),
+	"prevall":   filteredAxis((*goquery.Selection).PrevAll, (*goquery.Selection).PrevAllMatcher),
+	"nextuntil": untilAxis((*goquery.Selection).NextUntilMatcher, (*goquery.Selection).NextFilteredUntilMatcher),
+	"prevuntil": untilAxis((*goquery.Selection).PrevUntilMatcher, (*goquery.Selection).PrevFilteredUntilMatcher),
+}
 
-	if i := strings.IndexByte(s, '('); i > -1 {
-		name = s[:i]
-		if j := strings.LastIndexByte(s, ')'); j > -1 && i < j {
-			args = s[i+1 : j]
+// filteredAxis selects with all without an arg, if it may, and
+// with filtered otherwise.
+func filteredAxis(all func(*goquery.Selection) *goquery.Selection, filtered func(*goquery.Selection, goquery.Matcher) *goquery.Selection) func(string) (query, error) {
+	return func(arg string) (query, error) {
+		if arg == "" && all != nil {
+			return all, nil
 		}
-	} else {
-		name = s
-	}
-
-	return name, args
-
-}
-
-func parseTag(tag reflect.StructTag) (*path, error) {
-	p := &path{}
-	for i, part := range strings.Split(tag.Get("sq"), " | ") {
-		part = strings.TrimSpace(part)
-		switch i {
-		case 0:
-			p.selector = part
-		case 1:
-			switch {
-			case strings.HasPrefix(part, accessorAttr+"("),
-				accessorHTML == part,
-				accessorText == part:
-				p.acc = part
-			default:
-				return nil, fmt.Errorf("Bad accessor: %q", part)
-			}
-		default:
-			name, args := parseFunctionSignature(part)
-			if pf, exists := parseFuncs[name]; exists {
-				p.parsers = append(p.parsers, parser{f: pf, args: args})
-			} else if lf, exists := loadFuncs[name]; exists {
-				p.loader = &loader{lf, args}
-			} else {
-				return nil, fmt.Errorf("%q not registered func", name)
+		m, err := compileCSS(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(sel *goquery.Selection) *goquery.Selection { return filtered(sel, m) }, nil
+	}
+}
+
+// untilAxis selects up to the nodes matching the first selector of
+// its arg, with filtered if the arg has a second.
+func untilAxis(until func(*goquery.Selection, goquery.Matcher) *goquery.Selection, filtered func(*goquery.Selection, goquery.Matcher, goquery.Matcher) *goquery.Selection) func(string) (query, error) {
+	return func(arg string) (query, error) {
+		u, f := splitArg(arg)
+		um, err := compileCSS(u)
+		if err != nil {
+			return nil, err
+		}
+		if f == "" {
+			return func(sel *goquery.Selection) *goquery.Selection { return until(sel, um) }, nil
+		}
+		fm, err := compileCSS(f)
+		if err != nil {
+			return nil, err
+		}
+		return func(sel *goquery.Selection) *goquery.Selection { return filtered(sel, fm, um) }, nil
+	}
+}
+
+// cutAxis splits a selector starting with an axis call into the
+// axis, its arg and the rest of the selector.
+func cutAxis(selector string) (func(string) (query, error), string, string, bool) {
+	i := strings.IndexByte(selector, '(')
+	if i == -1 {
+		return nil, "", "", false
+	}
+	axis, exists := axes[selector[:i]]
+	if !exists {
+		return nil, "", "", false
+	}
+	depth := 0
+	for j := i; j < len(selector); j++ {
+		switch selector[j] {
+		case '\\':
+			j++
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return axis, strings.TrimSpace(selector[i+1 : j]), strings.TrimSpace(selector[j+1:]), true
 			}
 		}
 	}
+	return nil, "", "", false
+}
+
+// splitArg splits arg at its first top level comma.
+func splitArg(arg string) (string, string) {
+	depth := 0
+	for i := 0; i < len(arg); i++ {
+		switch arg[i] {
+		case '"', '\'':
+			if j, err := skipQuoted(arg, i); err == nil {
+				i = j
+			}
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				return strings.TrimSpace(arg[:i]), strings.TrimSpace(arg[i+1:])
+			}
+		}
+	}
+	return arg, ""
+}
+
+// options are the names accepted after a tag's pipeline.
+var options = map[string]bool{
+	"key":      true,
+	"value":    true,
+	"optional": true,
+	"required": true,
+	"default":  true,
+	"loader":   true,
+
+	"limit":       true,
+	"offset":      true,
+	"where":       true,
+	"skipinvalid": true,
+	"unique":      true,
+	"group":       true,
+
+	"in": true,
+}
+
+func isOption(name string) bool {
+	return options[name]
+}
+
+func (s *Scraper) parseTag(tag reflect.StructTag) (*path, error) {
+	value := tag.Get("sq")
+	p, err := s.parsePath(value)
+	if err != nil {
+		// errors in nested pipelines refer to the whole tag
+		err.(*TagError).Tag = value
+		return nil, err
+	}
 	if p.selector == "" {
 		if strings.Contains(string(tag), "sq:") {
-			return nil, fmt.Errorf("Bad tag: %q", tag)
+			return nil, &TagError{Tag: string(tag), Stage: "selector", Err: fmt.Errorf("%w: %q", ErrBadTag, tag)}
 		}
 		return nil, ErrTagNotFound
 	}
 	return p, nil
 }
+
+// parsePath parses a tag's pipelines and its options.
+func (s *Scraper) parsePath(value string) (*path, error) {
+	alts, opts, err := lexTag(value, isOption)
+	if err != nil {
+		return nil, &TagError{Tag: value, Stage: "syntax", Err: err}
+	}
+	var p *path
+	for i, segs := range alts {
+		ap, err := s.parsePipeline(value, segs)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			p = ap
+			continue
+		}
+		if ap.selector == "" {
+			return nil, &TagError{Tag: value, Stage: "syntax", Err: syntaxError(segs[0].col, "empty alternative")}
+		}
+		p.alts = append(p.alts, ap)
+	}
+	for _, o := range opts {
+		switch o.name {
+		case "key", "value":
+			sub, err := s.parsePath(o.args)
+			if err != nil {
+				return nil, err
+			}
+			if sub.selector == "" {
+				sub.selector = "."
+			}
+			if o.name == "key" {
+				p.key = sub
+			} else {
+				p.value = sub
+			}
+		case "optional":
+			p.optional = true
+		case "required":
+			p.required = true
+		case "default":
+			def := o.args
+			p.def = &def
+		case "loader":
+			i := s.typeLoaderIndex(o.args)
+			if i == -1 {
+				return nil, &TagError{Tag: value, Stage: "loader", Err: fmt.Errorf("%q %w", o.args, ErrUnknownTypeLoader)}
+			}
+			tl := s.typeLoaders[i]
+			p.typeLoader = &tl
+		case "limit", "offset":
+			n, err := strconv.Atoi(strings.TrimSpace(o.args))
+			if err != nil || n < 0 || (n == 0 && o.name == "limit") {
+				return nil, &TagError{Tag: value, Stage: o.name, Err: fmt.Errorf("%w: %s(%s) requires a positive count", ErrBadTag, o.name, o.args)}
+			}
+			if o.name == "limit" {
+				p.collect().limit = n
+			} else {
+				p.collect().offset = n
+			}
+		case "where":
+			where := strings.TrimSpace(o.args)
+			if where == "" {
+				return nil, &TagError{Tag: value, Stage: o.name, Err: fmt.Errorf("%w: where() requires a selector", ErrBadTag)}
+			}
+			q, err := compileQuery(where)
+			if err != nil {
+				return nil, &TagError{Tag: value, Stage: o.name, Err: err}
+			}
+			p.collect().where = q
+		case "group":
+			group := strings.TrimSpace(o.args)
+			if group == "" {
+				return nil, &TagError{Tag: value, Stage: o.name, Err: fmt.Errorf("%w: group() requires a selector", ErrBadTag)}
+			}
+			if strings.HasPrefix(group, xpathPrefix) {
+				return nil, &TagError{Tag: value, Stage: o.name, Err: fmt.Errorf("%w: group() requires a css selector", ErrBadTag)}
+			}
+			m, err := compileCSS(group)
+			if err != nil {
+				return nil, &TagError{Tag: value, Stage: o.name, Err: err}
+			}
+			p.collect().group = m
+		case "in":
+			loc, err := time.LoadLocation(strings.TrimSpace(o.args))
+			if err != nil || strings.TrimSpace(o.args) == "" {
+				return nil, &TagError{Tag: value, Stage: o.name, Err: fmt.Errorf("%w: in(%s) requires a time zone, ie. Europe/Paris", ErrBadTag, o.args)}
+			}
+			p.location = loc
+		case "skipinvalid":
+			p.collect().skipInvalid = true
+		case "unique":
+			field := strings.TrimSpace(o.args)
+			p.collect().unique = &field
+		}
+	}
+	for _, ap := range append([]*path{p}, p.alts...) {
+		if p.typeLoader != nil && ap.loader != nil {
+			return nil, &TagError{Tag: value, Stage: "loader", Err: fmt.Errorf("%w: loader(%s) conflicts with %s()", ErrBadTag, p.typeLoader.name, ap.loader.name)}
+		}
+	}
+	if p.required && (p.optional || p.def != nil) {
+		return nil, &TagError{Tag: value, Stage: "required", Err: fmt.Errorf("%w: required conflicts with optional and default()", ErrBadTag)}
+	}
+	return p, nil
+}
+
+// collect returns the collection options of p.
+func (p *path) collect() *collection {
+	if p.collection == nil {
+		p.collection = &collection{}
+	}
+	return p.collection
+}
+
+// elements returns the selections the elements of a slice are
+// hydrated from: the nodes of sel, or their groups, that match
+// where, past offset.  limit is applied to the hydrated elements,
+// as elements may be dropped by skipinvalid and unique().
+func (c *collection) elements(sel *goquery.Selection) []*goquery.Selection {
+	var elems []*goquery.Selection
+	if c.group != nil {
+		elems = groups(sel, c.group)
+	} else {
+		sel.Each(func(_ int, sel *goquery.Selection) {
+			elems = append(elems, sel)
+		})
+	}
+	if c.where != nil {
+		kept := elems[:0]
+		for _, elem := range elems {
+			if c.where(elem).Length() > 0 {
+				kept = append(kept, elem)
+			}
+		}
+		elems = kept
+	}
+	if c.offset >= len(elems) {
+		return nil
+	}
+	return elems[c.offset:]
+}
+
+// groups splits the element children of each node of sel into
+// runs starting at a child matching boundary.  Children before
+// the first boundary belong to no group.
+func groups(sel *goquery.Selection, boundary goquery.Matcher) []*goquery.Selection {
+	var groups []*goquery.Selection
+	sel.Each(func(_ int, parent *goquery.Selection) {
+		var group []*html.Node
+		parent.Children().Each(func(_ int, child *goquery.Selection) {
+			if child.IsMatcher(boundary) {
+				if group != nil {
+					groups = append(groups, sel.FindNodes().AddNodes(group...))
+				}
+				group = []*html.Node{}
+			}
+			if group != nil {
+				group = append(group, child.Nodes[0])
+			}
+		})
+		if group != nil {
+			groups = append(groups, sel.FindNodes().AddNodes(group...))
+		}
+	})
+	return groups
+}
+
+// parsePipeline parses the selector and stages of one of the
+// pipelines of the tag value.
+func (s *Scraper) parsePipeline(value string, segs []segment) (*path, error) {
+	p := &path{tag: value}
+	for i, seg := range segs {
+		if i == 0 {
+			p.selector = strings.TrimSpace(seg.text)
+			if strings.HasPrefix(p.selector, "@") {
+				pf, exists := pseudoFields[p.selector[1:]]
+				if !exists {
+					return nil, &TagError{Tag: value, Stage: "selector", Err: fmt.Errorf("%w: unknown pseudo-field %q", ErrBadTag, p.selector)}
+				}
+				p.pseudo = pf
+			} else {
+				q, err := compileQuery(p.selector)
+				if err != nil {
+					return nil, &TagError{Tag: value, Stage: "selector", Err: err}
+				}
+				p.query = q
+			}
+			continue
+		}
+		c, err := parseCall(seg)
+		if err != nil {
+			return nil, &TagError{Tag: value, Stage: "syntax", Err: err}
+		}
+		// pseudo-fields are followed by funcs
+		if i == 1 && p.pseudo == nil {
+			def, exists := s.accessors[c.name]
+			if !exists || !def.accepts(c.hasArgs) {
+				return nil, &TagError{Tag: value, Stage: "accessor", Err: fmt.Errorf("%w: %q", ErrBadAccessor, strings.TrimSpace(seg.text))}
+			}
+			p.acc = c.name
+			if c.hasArgs {
+				p.acc += "(" + c.args + ")"
+			}
+			p.accessor = &accessor{name: c.name, args: c.args, accessorDef: def}
+			continue
+		}
+		check, builtin := s.argCheckers[c.name]
+		if builtin {
+			if err := check(c.args); err != nil {
+				return nil, &TagError{Tag: value, Stage: c.name, Err: fmt.Errorf("%s(%s): %w", c.name, c.args, err)}
+			}
+		}
+		if pf, exists := s.parseFuncs[c.name]; exists {
+			pr := parser{name: c.name, f: pf, args: c.args}
+			// the checker validated the pattern
+			if f, exists := regexpParsers[c.name]; exists && builtin {
+				r := regexp.MustCompile(c.args)
+				pr.bound = func(s string) (string, error) { return f(r, s) }
+			}
+			if p.splitter != nil {
+				p.each = append(p.each, pr)
+			} else {
+				p.parsers = append(p.parsers, pr)
+			}
+		} else if lf, exists := s.loadFuncs[c.name]; exists {
+			p.loader = &loader{name: c.name, f: lf, args: c.args}
+		} else if sf, exists := splitFuncs[c.name]; exists {
+			if p.splitter != nil {
+				return nil, &TagError{Tag: value, Stage: c.name, Err: fmt.Errorf("%w: %s() follows %s()", ErrBadTag, c.name, p.splitter.name)}
+			}
+			p.splitter = &splitter{name: c.name, f: sf, args: c.args, named: namedSplitters[c.name]}
+			if f, exists := regexpSplitters[c.name]; exists && builtin {
+				r := regexp.MustCompile(c.args)
+				p.splitter.bound = func(s string) ([]entry, error) { return f(r, s) }
+			}
+		} else {
+			return nil, &TagError{Tag: value, Stage: c.name, Err: fmt.Errorf("%q %w", c.name, ErrUnknownFunc)}
+		}
+	}
+	return p, nil
+}
@@ -1,6 +1,7 @@
 package sq
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -29,7 +30,15 @@ func TestExtractString(t *testing.T) {
 		t.Error(err)
 	}
 
-	s, err := extractString(doc.Find("title"), accessorText)
+	extractString := func(sel *goquery.Selection, acc string) (string, error) {
+		p, err := defaultScraper.parsePath(". | " + acc)
+		if err != nil {
+			return "", err
+		}
+		return p.accessor.extract(sel)
+	}
+
+	s, err := extractString(doc.Find("title"), "text")
 	if err != nil {
 		t.Error(err)
 	}
@@ -37,7 +46,7 @@ func TestExtractString(t *testing.T) {
 		t.Errorf("Expected %q, got %q", title, s)
 	}
 
-	s, err = extractString(doc.Find("[data-attr]"), fmt.Sprintf("%s(data-attr)", accessorAttr))
+	s, err = extractString(doc.Find("[data-attr]"), "attr(data-attr)")
 	if err != nil {
 		t.Error(err)
 	}
@@ -46,7 +55,7 @@ func TestExtractString(t *testing.T) {
 	}
 
 	expected := fmt.Errorf("%s: %v", ErrAttributeNotFound, "attr(missing)")
-	s, err = extractString(doc.Find("[data-attr]"), fmt.Sprintf("%s(missing)", accessorAttr))
+	s, err = extractString(doc.Find("[data-attr]"), "attr(missing)")
 	if err.Error() != expected.Error() {
 		t.Errorf("Expected %q, got %q", expected, err)
 	}
@@ -54,7 +63,7 @@ func TestExtractString(t *testing.T) {
 		t.Errorf("Expected empty string, got %q", s)
 	}
 
-	s, err = extractString(doc.Find("p"), accessorHTML)
+	s, err = extractString(doc.Find("p"), "html")
 	if err != nil {
 		t.Error(err)
 	}
@@ -71,6 +80,100 @@ func TestExtractString(t *testing.T) {
 		t.Errorf("Expected empty string, got %q", s)
 	}
 
+	expected = fmt.Errorf("Bad accessor: %q", "text(x)")
+	if _, err = extractString(doc.Find("p"), "text(x)"); err == nil || err.Error() != expected.Error() {
+		t.Errorf("Expected %q, got %q", expected, err)
+	}
+
+}
+
+func TestAccessors(t *testing.T) {
+
+	const testHTML = `
+		<div id="d" class="a b" data-x='"q"'>own <b>bold</b> text
+			<p>para  one<br>line two</p>
+			<script>ignored()</script>
+			<table><tr><td>1</td><td>2</td></tr></table>
+		</div>
+		<form>
+			<input name="q" value=" go ">
+			<input type="checkbox" name="c" checked>
+			<input name="empty">
+			<textarea> notes </textarea>
+			<select><option value="a">A</option><option selected>B  b</option></select>
+			<select><option value="first">F</option></select>
+		</form>
+		<table id="t">
+			<tr>
+				<th>a</th>
+				<th>b</th>
+			</tr>
+		</table>
+		<span title="C:\\dir café">x</span>
+	`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		selector, acc, expected string
+	}{
+		{"b", "outerhtml", "<b>bold</b>"},
+		{"#d", "owntext", "own  text"},
+		{"#d", "innertext", "own bold text\npara one\nline two\n1\t2"},
+		{"#d", "attrs", `id="d" class="a b" data-x="&#34;q&#34;"`},
+		{"#t", "innertext", "a\tb"},
+		{"span", "attrs", `title="C:\\dir café"`},
+		{"#d", "tag", "div"},
+		{"input[name=q]", "value", "go"},
+		{"input[name=c]", "value", "on"},
+		{"input[name=empty]", "value", ""},
+		{"textarea", "value", "notes"},
+		{"select", "value", "B b"},
+		{"select + select", "value", "first"},
+		{"td", "count", "2"},
+	}
+
+	for _, test := range tests {
+		p, err := defaultScraper.parsePath(test.selector + " | " + test.acc)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		s, err := p.accessor.extract(doc.Find(test.selector))
+		if err != nil {
+			t.Errorf("%s: %v", test.acc, err)
+		}
+		if s != test.expected {
+			t.Errorf("%s: Expected %q, got %q", test.acc, test.expected, s)
+		}
+	}
+
+	if _, err := defaultScraper.accessors["value"].f(doc.Find("#d"), ""); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("Expected %q, got %q", ErrAttributeNotFound, err)
+	}
+
+	var page struct {
+		Cells   int    `sq:"td | count"`
+		Missing int    `sq:"li | count"`
+		Shout   string `sq:"b | upper"`
+		Data    string `sq:"#d | data(x)"`
+	}
+	s := New(WithAccessor("upper", func(sel *goquery.Selection, _ string) (string, error) {
+		return strings.ToUpper(sel.Text()), nil
+	}))
+	s.RegisterAccessor("data", func(sel *goquery.Selection, name string) (string, error) {
+		return sel.AttrOr("data-"+name, ""), nil
+	})
+	if errs := s.ScrapeSelection(&page, doc.Selection); len(errs) > 0 {
+		t.Error(errs)
+	}
+	if page.Cells != 2 || page.Missing != 0 || page.Shout != "BOLD" || page.Data != `"q"` {
+		t.Errorf("Expected 2, 0, BOLD and \"q\", got %+v", page)
+	}
+
 }
 
 func TestParseTag(t *testing.T) {
@@ -121,17 +224,67 @@ func TestParseTag(t *testing.T) {
 			nil,
 		},
 
+		{`sq:"p.last | text | regexp(a | b) | append(\" | \")"`,
+			&path{
+				selector: "p.last", acc: "text",
+				parsers: []parser{
+					parser{args: "a | b", f: parseFuncs["regexp"]},
+					parser{args: " | ", f: parseFuncs["append"]},
+				},
+			},
+			nil,
+		},
+		{`sq:"td:nth-child(2) | attr(data-x) | regexp((\\d+)-(\\d+)) | regexp([)|]) | prepend('it\\'s, ')"`,
+			&path{
+				selector: "td:nth-child(2)", acc: "attr(data-x)",
+				parsers: []parser{
+					parser{args: "(\\d+)-(\\d+)", f: parseFuncs["regexp"]},
+					parser{args: "[)|]", f: parseFuncs["regexp"]},
+					parser{args: "it's, ", f: parseFuncs["prepend"]},
+				},
+			},
+			nil,
+		},
+		// quotes are kept unless they protect a pipe or comma
+		{`sq:"p | text | regexp(\"(.*)\") | strip(\") | append('x' y) | append(\" \") | append('a', 'b')"`,
+			&path{
+				selector: "p", acc: "text",
+				parsers: []parser{
+					parser{args: `"(.*)"`, f: parseFuncs["regexp"]},
+					parser{args: `"`, f: parseFuncs["strip"]},
+					parser{args: `'x' y`, f: parseFuncs["append"]},
+					parser{args: `" "`, f: parseFuncs["append"]},
+					parser{args: `'a', 'b'`, f: parseFuncs["append"]},
+				},
+			},
+			nil,
+		},
+		{`sq:"[title='a | b'] | text | regexp(\\(\\d+\\))"`,
+			&path{
+				selector: "[title='a | b']", acc: "text",
+				parsers: []parser{parser{args: "\\(\\d+\\)", f: parseFuncs["regexp"]}},
+			},
+			nil,
+		},
+
 		// bad
 		{`sq:"p.last | fuzzy"`, nil, fmt.Errorf("Bad accessor: %q", `fuzzy`)},
+		{`sq:"p.last | text | regexp((\\d+)"`, nil, errors.New("Bad tag: unclosed '(' at column 23")},
+		{`sq:"p.last | text | regexp(\\d+))"`, nil, errors.New("Bad tag: unexpected ')' at column 28")},
+		{`sq:"p[title='x] | text"`, nil, errors.New("Bad tag: unterminated quote at column 9")},
+		{`sq:"p.last | text | append(x)y"`, nil, errors.New(`Bad tag: unexpected "y" after "append(x)" at column 26`)},
+		{`sq:"p.last | text | | append(x)"`, nil, errors.New("Bad tag: empty stage at column 16")},
+		{`sq:"p.last | text ||| text"`, nil, errors.New("Bad tag: empty alternative at column 17")},
+		{`sq:"p.last | text || p | txt"`, nil, fmt.Errorf("Bad accessor: %q", `txt`)},
 		{`sq:"p.last | text | unregifunc"`, nil, fmt.Errorf("%q not registered func", "unregifunc")},
 		{`sq:"p.last\d"`, nil, fmt.Errorf("Bad tag: %q", `sq:"p.last\d"`)},
 		{``, nil, ErrTagNotFound},
 	}
 
 	for _, test := range tests {
-		p, err := parseTag(test.tag)
-		if err != nil {
-			if err.Error() != test.err.Error() {
+		p, err := defaultScraper.parseTag(test.tag)
+		if err != nil || test.err != nil {
+			if err == nil || test.err == nil || err.Error() != test.err.Error() {
 				t.Errorf("Expected error %q, got %q", test.err, err)
 			}
 			continue
@@ -167,3 +320,59 @@ func TestParseTag(t *testing.T) {
 	}
 
 }
+
+func TestPseudoClasses(t *testing.T) {
+
+	const testHTML = `
+		<table>
+			<tr><th>Name</th><td>Widget</td></tr>
+			<tr><th>Price (USD)</th><td>$12</td></tr>
+			<tr><th>price</th><td>lower</td></tr>
+		</table>
+		<div class="pager"><a href="/1">Prev</a> <a href="/3">Next  page</a></div>
+		<p class="note">Total: <b>3 items</b></p>
+		<p class="greeting">Hello world</p>
+	`
+
+	var page struct {
+		Price      string   `sq:"th:contains(\"Price (USD)\") + td | text"`
+		Prices     []string `sq:"th:icontains(price) + td | text"`
+		Next       string   `sq:"a:matches(^Next) | attr(href)"`
+		NextFold   string   `sq:"a:imatches(^next\\s) | attr(href)"`
+		Page       string   `sq:"a:has-text('Next page') | attr(href)"`
+		PageFold   string   `sq:"a:ihas-text(NEXT PAGE) | attr(href)"`
+		Own        string   `sq:"p:containsOwn(Total) | text"`
+		NotOwn     string   `sq:"p:containsOwn(items) | text, optional"`
+		OwnFold    string   `sq:"p:icontainsOwn(total) b | text"`
+		Sibling    string   `sq:"th:contains(Name) | text"`
+		Has        string   `sq:"tr:has(th:contains(Name)) td:contains(Wid) | text"`
+		Attributes string   `sq:"[href=':contains(x)'], a:contains(Prev) | text"`
+		Fold       string   `sq:"p:contains(hello) | text"`
+		OwnFold2   string   `sq:"p:containsOwn(TOTAL) | text"`
+		Case       string   `sq:"p:containsCase(hello) | text, optional"`
+		OwnCase    string   `sq:"p:containsOwnCase(Total) | text"`
+	}
+
+	if errs := Scrape(&page, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	expected := []string{"$12", "/3", "/3", "/3", "/3", "Total: 3 items", "", "3 items", "Name", "Widget", "Prev", "Hello world", "Total: 3 items", "", "Total: 3 items"}
+	got := []string{page.Price, page.Next, page.NextFold, page.Page, page.PageFold, page.Own, page.NotOwn, page.OwnFold, page.Sibling, page.Has, page.Attributes, page.Fold, page.OwnFold2, page.Case, page.OwnCase}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if !reflect.DeepEqual(page.Prices, []string{"$12", "lower"}) {
+		t.Errorf("Unexpected prices %q", page.Prices)
+	}
+
+	var bad struct {
+		Regexp string `sq:"a:imatches(x{2,1}) | text"`
+		Quote  string `sq:"a:contains('x' y) | text"`
+	}
+	errs := Validate(bad)
+	if len(errs) != 2 || !errors.Is(errs[0], ErrInvalidRegexp) || !errors.Is(errs[1], ErrBadTag) {
+		t.Errorf("Expected %q and %q, got %q", ErrInvalidRegexp, ErrBadTag, errs)
+	}
+
+}
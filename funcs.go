@@ -8,7 +8,6 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
-	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	douceur "github.com/aymerick/douceur/parser"
@@ -21,51 +20,72 @@ type (
 
 	LoadFunc func(sel *goquery.Selection, s, arg string) (interface{}, error)
 
+	// Unmarshaler is implemented by types that load themselves
+	// from a matched selection and the text emitted by its
+	// pipeline.
+	Unmarshaler interface {
+		UnmarshalSQ(sel *goquery.Selection, text string) error
+	}
+
+	// TypeLoader loads values of the types it matches.  Loaders
+	// are tried in order of priority, highest first, and among
+	// equal priorities the most recently registered wins, so
+	// loaders registered by users take precedence over built-ins.
 	TypeLoader struct {
-		isType func(t reflect.Type) bool
-		load   func(sel *goquery.Selection, s string) (interface{}, error)
+		name     string
+		priority int
+		isType   func(t reflect.Type) bool
+		load     func(sel *goquery.Selection, s string) (interface{}, error)
+		// builtin loaders may be reconfigured by the
+		// Scraper's options, ie. WithLocale.
+		builtin bool
 	}
 
 	parser struct {
+		name string
 		f    ParseFunc
 		args string
+		// bound is f with its compiled args, set
+		// for the built-in funcs taking a regexp.
+		bound func(s string) (string, error)
 	}
 	loader struct {
+		name string
 		f    LoadFunc
 		args string
 	}
+
+	// splitFunc splits s into the values of a collection, or
+	// into the named values of a struct or map.
+	splitFunc func(s, arg string) ([]entry, error)
+
+	// entry is a value split from text, named if it sets a
+	// struct field or map entry.
+	entry struct {
+		name, value string
+	}
+
+	splitter struct {
+		name string
+		f    splitFunc
+		args string
+		// named splitters load struct fields and map
+		// entries rather than collection elements.
+		named bool
+		// bound is f with its compiled args.
+		bound func(s string) ([]entry, error)
+	}
 )
 
 var (
 	ErrNoRegexpMatch = errors.New("regexp did not match the content")
+	ErrInvalidRegexp = errors.New("invalid regexp")
 )
 
 var (
 	parseFuncs = map[string]ParseFunc{
-		"regexp": func(s, pattern string) (string, error) {
-			r, err := regexp.Compile(pattern)
-			if err != nil {
-				return "", err
-			}
-			matches := r.FindStringSubmatch(s)
-			if len(matches) == 1 {
-				return matches[0], nil
-			}
-			if len(matches) > 1 {
-				if len(matches[1]) == 0 {
-					return "", ErrNoRegexpMatch
-				}
-				return matches[1], nil
-			}
-			return "", ErrNoRegexpMatch
-		},
-		"strip": func(s, pattern string) (string, error) {
-			r, err := regexp.Compile(pattern)
-			if err != nil {
-				return "", err
-			}
-			return r.ReplaceAllString(s, ""), nil
-		},
+		"regexp": compiling(matchRegexp),
+		"strip":  compiling(stripRegexp),
 		"path.prepend": func(s, token string) (string, error) {
 			if strings.HasPrefix(s, token) {
 				return s, nil
@@ -95,16 +115,75 @@ var (
 	}
 
 	loadFuncs = map[string]LoadFunc{
-		"time": func(_ *goquery.Selection, s, layout string) (interface{}, error) {
-			return time.Parse(strings.TrimSpace(layout), strings.TrimSpace(s))
-		},
+		"time": (&timeParser{}).load,
 		"ago": func(_ *goquery.Selection, s, _ string) (interface{}, error) {
 			return ago.Parse(strings.TrimSpace(s))
 		},
+		"number": numberLoader(nil),
+	}
+
+	// splitFuncs split the text of a pipeline into several
+	// values.  The funcs following them apply to each value.
+	splitFuncs = map[string]splitFunc{
+		// regexp.all returns every match, or the first
+		// group of every match if the pattern has any.
+		"regexp.all": compiling(allRegexp),
+		// split returns the non-empty values between sep,
+		// or between runs of whitespace without one.
+		"split": func(s, sep string) ([]entry, error) {
+			if strings.TrimSpace(sep) == "" {
+				return splitValues(strings.Fields(s)), nil
+			}
+			return splitValues(strings.Split(s, sep)), nil
+		},
+		// split.regexp returns the non-empty values between
+		// matches of the pattern.
+		"split.regexp": compiling(splitRegexp),
+		// regexp.named returns the named groups of the
+		// first match that took part in it.
+		"regexp.named": compiling(namedRegexp),
+	}
+
+	// namedSplitters are the splitFuncs returning named values.
+	namedSplitters = map[string]bool{
+		"regexp.named": true,
+	}
+
+	// textLoaders are the built-in loadFuncs returning text,
+	// which is converted to the kind of the field.
+	textLoaders = map[string]bool{
+		"number": true,
+	}
+
+	// argCheckers validate the arguments of built-in
+	// funcs when a tag is compiled.  Overriding a func drops
+	// its checker.
+	argCheckers = map[string]func(args string) error{
+		"regexp":       checkRegexp,
+		"strip":        checkRegexp,
+		"regexp.all":   checkRegexp,
+		"regexp.named": checkNamedRegexp,
+		"split.regexp": checkRegexp,
+		"number":       checkLocale,
+		"time":         checkLayouts,
+	}
+
+	// regexpParsers and regexpSplitters are the built-in funcs
+	// taking a regexp, which tags compile once into their plan.
+	regexpParsers = map[string]func(r *regexp.Regexp, s string) (string, error){
+		"regexp": matchRegexp,
+		"strip":  stripRegexp,
+	}
+	regexpSplitters = map[string]func(r *regexp.Regexp, s string) ([]entry, error){
+		"regexp.all":   allRegexp,
+		"split.regexp": splitRegexp,
+		"regexp.named": namedRegexp,
 	}
 
-	typeLoaders = map[string]TypeLoader{
-		"url": {
+	// typeLoaders are the built-in type loaders in resolution order.
+	typeLoaders = []TypeLoader{
+		{
+			name: "url",
 			isType: func(t reflect.Type) bool {
 				return t.PkgPath() == "net/url" && t.Name() == "URL"
 			},
@@ -112,7 +191,8 @@ var (
 				return url.Parse(s)
 			},
 		},
-		"goquery": {
+		{
+			name: "goquery",
 			isType: func(t reflect.Type) bool {
 				return strings.HasSuffix(t.PkgPath(), "/goquery") && t.Name() == "Selection"
 			},
@@ -120,15 +200,20 @@ var (
 				return sel.Clone(), nil
 			},
 		},
-		"html": {
+		{
+			name: "html",
 			isType: func(t reflect.Type) bool {
 				return t.PkgPath() == "golang.org/x/net/html" && t.Name() == "Node"
 			},
 			load: func(sel *goquery.Selection, _ string) (interface{}, error) {
+				if sel.Size() == 0 {
+					return nil, ErrNodeNotFound
+				}
 				return sel.Clone().Nodes[0], nil
 			},
 		},
-		"otto": {
+		{
+			name: "otto",
 			isType: func(t reflect.Type) bool {
 				return strings.HasSuffix(t.PkgPath(), "otto/ast") && t.Name() == "Program"
 			},
@@ -139,7 +224,8 @@ var (
 				return otto.ParseFile(nil, "", text, 0)
 			},
 		},
-		"css": {
+		{
+			name: "css",
 			isType: func(t reflect.Type) bool {
 				return strings.HasSuffix(t.PkgPath(), "douceur/css") && t.Name() == "Stylesheet"
 			},
@@ -150,25 +236,171 @@ var (
 				return douceur.Parse(text)
 			},
 		},
+		{
+			name: "time",
+			isType: func(t reflect.Type) bool {
+				return t == timeType
+			},
+			load: timeLoader(&timeParser{}),
+		},
+		{
+			name: "money",
+			isType: func(t reflect.Type) bool {
+				return t == moneyType
+			},
+			load: moneyLoader(nil),
+		},
 	}
 )
 
+// RegisterParseFunc registers f on the default Scraper.
 func RegisterParseFunc(name string, f ParseFunc) {
-	parseFuncs[name] = f
+	defaultScraper.RegisterParseFunc(name, f)
 }
 
+// RegisterLoadFunc registers f on the default Scraper.
 func RegisterLoadFunc(name string, f LoadFunc) {
-	loadFuncs[name] = f
+	defaultScraper.RegisterLoadFunc(name, f)
 }
 
+// RegisterTypeLoader registers a type loader on the default Scraper.
 func RegisterTypeLoader(name string, isType func(t reflect.Type) bool, load func(sel *goquery.Selection, text string) (interface{}, error)) {
-	typeLoaders[name] = TypeLoader{
-		isType: isType,
-		load:   load,
+	defaultScraper.RegisterTypeLoader(name, isType, load)
+}
+
+// RegisterTypeLoaderPriority registers a type loader with the given
+// priority on the default Scraper.
+func RegisterTypeLoaderPriority(name string, priority int, isType func(t reflect.Type) bool, load func(sel *goquery.Selection, text string) (interface{}, error)) {
+	defaultScraper.RegisterTypeLoaderPriority(name, priority, isType, load)
+}
+
+// UnregisterTypeLoader removes the type loader called name from the
+// default Scraper.
+func UnregisterTypeLoader(name string) bool {
+	return defaultScraper.UnregisterTypeLoader(name)
+}
+
+// TypeLoaders returns the names of the default Scraper's type
+// loaders in resolution order.
+func TypeLoaders() []string {
+	return defaultScraper.TypeLoaders()
+}
+
+// RegisterParseFunc adds or overrides the parse func called name.
+func (s *Scraper) RegisterParseFunc(name string, f ParseFunc) {
+	s.mu.Lock()
+	s.setParseFunc(name, f)
+	s.resetPlans()
+	s.mu.Unlock()
+}
+
+// RegisterLoadFunc adds or overrides the load func called name.
+func (s *Scraper) RegisterLoadFunc(name string, f LoadFunc) {
+	s.mu.Lock()
+	s.setLoadFunc(name, f)
+	s.resetPlans()
+	s.mu.Unlock()
+}
+
+// RegisterTypeLoader adds or overrides the type loader called name
+// with priority 0.
+func (s *Scraper) RegisterTypeLoader(name string, isType func(t reflect.Type) bool, load func(sel *goquery.Selection, text string) (interface{}, error)) {
+	s.RegisterTypeLoaderPriority(name, 0, isType, load)
+}
+
+// RegisterTypeLoaderPriority adds or overrides the type loader called
+// name.  It is tried before loaders of lower priority and before
+// loaders of the same priority registered earlier.
+func (s *Scraper) RegisterTypeLoaderPriority(name string, priority int, isType func(t reflect.Type) bool, load func(sel *goquery.Selection, text string) (interface{}, error)) {
+	s.mu.Lock()
+	s.setTypeLoader(TypeLoader{name: name, priority: priority, isType: isType, load: load})
+	s.resetPlans()
+	s.mu.Unlock()
+}
+
+// UnregisterTypeLoader removes the type loader called name and
+// reports whether it was registered.
+func (s *Scraper) UnregisterTypeLoader(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.typeLoaderIndex(name)
+	if i == -1 {
+		return false
+	}
+	s.typeLoaders = append(s.typeLoaders[:i:i], s.typeLoaders[i+1:]...)
+	s.resetPlans()
+	return true
+}
+
+// TypeLoaders returns the names of the type loaders in resolution
+// order.
+func (s *Scraper) TypeLoaders() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, len(s.typeLoaders))
+	for i, tl := range s.typeLoaders {
+		names[i] = tl.name
+	}
+	return names
+}
+
+func (s *Scraper) setParseFunc(name string, f ParseFunc) {
+	s.parseFuncs[name] = f
+	delete(s.argCheckers, name)
+}
+
+func (s *Scraper) setLoadFunc(name string, f LoadFunc) {
+	s.loadFuncs[name] = f
+	delete(s.argCheckers, name)
+}
+
+// setTypeLoader replaces any loader called tl.name and inserts tl
+// ahead of the loaders it takes precedence over.
+func (s *Scraper) setTypeLoader(tl TypeLoader) {
+	if i := s.typeLoaderIndex(tl.name); i > -1 {
+		s.typeLoaders = append(s.typeLoaders[:i:i], s.typeLoaders[i+1:]...)
+	}
+	i := 0
+	for i < len(s.typeLoaders) && s.typeLoaders[i].priority > tl.priority {
+		i++
+	}
+	s.typeLoaders = append(s.typeLoaders[:i:i], append([]TypeLoader{tl}, s.typeLoaders[i:]...)...)
+}
+
+func (s *Scraper) typeLoaderIndex(name string) int {
+	for i, tl := range s.typeLoaders {
+		if tl.name == name {
+			return i
+		}
 	}
+	return -1
+}
+
+func checkRegexp(pattern string) error {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRegexp, err)
+	}
+	return nil
+}
+
+// checkNamedRegexp checks pattern has named groups.
+func checkNamedRegexp(pattern string) error {
+	if err := checkRegexp(pattern); err != nil {
+		return err
+	}
+	r := regexp.MustCompile(pattern)
+	for _, name := range r.SubexpNames() {
+		if name != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no named groups", ErrInvalidRegexp)
 }
 
 func (p parser) parse(s string) (string, error) {
+	if p.bound != nil {
+		return p.bound(s)
+	}
 	if p.f != nil {
 		return p.f(s, p.args)
 	}
@@ -181,3 +413,94 @@ func (l *loader) load(sel *goquery.Selection, s string) (interface{}, error) {
 	}
 	return s, nil
 }
+
+// splitValues trims parts and drops those left empty.
+func splitValues(parts []string) []entry {
+	var values []entry
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, entry{value: part})
+		}
+	}
+	return values
+}
+
+func (sp *splitter) split(s string) ([]entry, error) {
+	if sp.bound != nil {
+		return sp.bound(s)
+	}
+	return sp.f(s, sp.args)
+}
+
+// compiling adapts f to the funcs taking a pattern, which it
+// compiles on every call.  Tags bind the pattern they compiled
+// instead.
+func compiling[T any](f func(r *regexp.Regexp, s string) (T, error)) func(s, pattern string) (T, error) {
+	return func(s, pattern string) (T, error) {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		return f(r, s)
+	}
+}
+
+func matchRegexp(r *regexp.Regexp, s string) (string, error) {
+	matches := r.FindStringSubmatch(s)
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+	if len(matches) > 1 {
+		if len(matches[1]) == 0 {
+			return "", ErrNoRegexpMatch
+		}
+		return matches[1], nil
+	}
+	return "", ErrNoRegexpMatch
+}
+
+func stripRegexp(r *regexp.Regexp, s string) (string, error) {
+	return r.ReplaceAllString(s, ""), nil
+}
+
+func allRegexp(r *regexp.Regexp, s string) ([]entry, error) {
+	var values []entry
+	for _, m := range r.FindAllStringSubmatch(s, -1) {
+		if len(m) > 1 {
+			values = append(values, entry{value: m[1]})
+		} else {
+			values = append(values, entry{value: m[0]})
+		}
+	}
+	return values, nil
+}
+
+func splitRegexp(r *regexp.Regexp, s string) ([]entry, error) {
+	return splitValues(r.Split(s, -1)), nil
+}
+
+func namedRegexp(r *regexp.Regexp, s string) ([]entry, error) {
+	m := r.FindStringSubmatchIndex(s)
+	if m == nil {
+		return nil, ErrNoRegexpMatch
+	}
+	var values []entry
+	for i, name := range r.SubexpNames() {
+		if name == "" || m[2*i] == -1 {
+			continue
+		}
+		values = append(values, entry{name: name, value: s[m[2*i]:m[2*i+1]]})
+	}
+	return values, nil
+}
+
+// loader adapts tl to the loader used by a plan.
+func (tl TypeLoader) loader() *loader {
+	return &loader{
+		name: tl.name,
+		f: func(sel *goquery.Selection, text, _ string) (interface{}, error) {
+			return tl.load(sel, text)
+		},
+	}
+}
@@ -2,8 +2,14 @@ package sq
 
 import (
 	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
 func TestNilFuncs(t *testing.T) {
@@ -57,6 +63,398 @@ func TestRegexp(t *testing.T) {
 
 }
 
+func TestRegexpCaptures(t *testing.T) {
+
+	const testHTML = `
+		<p class="listing">3 beds, 2 baths, 1,200 sqft</p>
+		<p class="prices">$10, $1,250 and $7</p>
+	`
+
+	type details struct {
+		Beds  int
+		Baths int
+		Sqft  int
+		Lot   string
+	}
+
+	var page struct {
+		Details details           `sq:"p.listing | text | regexp.named((?P<Beds>\\d+) beds, (?P<Baths>\\d+) baths, (?P<Sqft>[\\d,]+) sqft) | strip(,)"`
+		Ptr     *details          `sq:"p.listing | text | regexp.named((?P<beds>\\d+) beds)"`
+		Map     map[string]string `sq:"p.listing | text | regexp.named((?P<beds>\\d+) beds(?P<lot> lot)?)"`
+		Prices  []int             `sq:"p.prices | text | regexp.all(\\$([\\d,]+)) | strip(,)"`
+		Words   []string          `sq:"p.prices | text | regexp.all([a-z]+)"`
+		First   [2]string         `sq:"p.prices | text | regexp.all(\\d+)"`
+		None    []string          `sq:"p.listing | text | regexp.all(x)"`
+	}
+
+	if errs := Scrape(&page, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	if page.Details != (details{Beds: 3, Baths: 2, Sqft: 1200}) {
+		t.Errorf("Unexpected details %+v", page.Details)
+	}
+	if page.Ptr == nil || page.Ptr.Beds != 3 {
+		t.Errorf("Unexpected details %+v", page.Ptr)
+	}
+	if !reflect.DeepEqual(page.Map, map[string]string{"beds": "3"}) {
+		t.Errorf("Unexpected map %v", page.Map)
+	}
+	if !reflect.DeepEqual(page.Prices, []int{10, 1250, 7}) {
+		t.Errorf("Unexpected prices %v", page.Prices)
+	}
+	if !reflect.DeepEqual(page.Words, []string{"and"}) {
+		t.Errorf("Unexpected words %q", page.Words)
+	}
+	if page.First != [2]string{"10", "1"} {
+		t.Errorf("Unexpected first %q", page.First)
+	}
+	if page.None == nil || len(page.None) != 0 {
+		t.Errorf("Expected an empty slice, got %#v", page.None)
+	}
+
+	var nomatch struct {
+		Details details `sq:"p.listing | text | regexp.named((?P<Beds>\\d+) rooms)"`
+	}
+	errs := Scrape(&nomatch, strings.NewReader(testHTML))
+	var fe *FieldError
+	if len(errs) != 1 || !errors.As(errs[0], &fe) || fe.Stage != "regexp.named" || !errors.Is(errs[0], ErrNoRegexpMatch) {
+		t.Errorf("Expected a regexp.named %q error, got %q", ErrNoRegexpMatch, errs)
+	}
+
+	// values the pattern matches may still fail to convert
+	var unconverted struct {
+		Prices []int `sq:"p.prices | text | regexp.all(\\$[\\d,]+)"`
+	}
+	errs = Scrape(&unconverted, strings.NewReader(testHTML))
+	if len(errs) != 3 {
+		t.Errorf("Expected an error for each price, got %q", errs)
+	}
+	for _, err := range errs {
+		if !errors.As(err, &fe) || fe.Stage != "conversion" || !errors.Is(err, strconv.ErrSyntax) {
+			t.Errorf("Expected a conversion error, got %q", err)
+		}
+	}
+
+	var bad struct {
+		Unnamed details `sq:"p | text | regexp.named(\\d+)"`
+		Scalar  string  `sq:"p | text | regexp.all(\\d+)"`
+		Twice   []int   `sq:"p | text | regexp.all(\\d+) | regexp.all(\\d)"`
+	}
+	if errs := Validate(bad); len(errs) != 3 || !errors.Is(errs[0], ErrInvalidRegexp) || !errors.Is(errs[1], ErrBadTag) || !errors.Is(errs[2], ErrBadTag) {
+		t.Errorf("Unexpected errors %q", errs)
+	}
+
+}
+
+func TestSplit(t *testing.T) {
+
+	const testHTML = `
+		<table><tr>
+			<td class="colors">red, green, , blue</td>
+			<td class="sizes">1 / 2/3</td>
+			<td class="dates">2006; 2016</td>
+			<td class="tags">a b
+				c</td>
+			<td class="links"><a href="/a">a</a><a href="/b">b</a></td>
+		</tr></table>
+	`
+
+	var page struct {
+		Colors []string    `sq:"td.colors | text | split(,)"`
+		Sizes  []int       `sq:"td.sizes | text | split.regexp(\\s*/\\s*)"`
+		Pair   [2]int      `sq:"td.sizes | text | split(/)"`
+		Dates  []time.Time `sq:"td.dates | text | split(;) | time(2006)"`
+		Tags   []string    `sq:"td.tags | text | split() | prepend(#)"`
+		Quoted []string    `sq:"td.colors | text | split(', ')"`
+	}
+
+	if errs := Scrape(&page, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	if !reflect.DeepEqual(page.Colors, []string{"red", "green", "blue"}) {
+		t.Errorf("Unexpected colors %q", page.Colors)
+	}
+	if !reflect.DeepEqual(page.Sizes, []int{1, 2, 3}) {
+		t.Errorf("Unexpected sizes %v", page.Sizes)
+	}
+	if page.Pair != [2]int{1, 2} {
+		t.Errorf("Unexpected pair %v", page.Pair)
+	}
+	if len(page.Dates) != 2 || page.Dates[1].Year() != 2016 {
+		t.Errorf("Unexpected dates %v", page.Dates)
+	}
+	if !reflect.DeepEqual(page.Tags, []string{"#a", "#b", "#c"}) {
+		t.Errorf("Unexpected tags %q", page.Tags)
+	}
+	if !reflect.DeepEqual(page.Quoted, []string{"red", "green", "blue"}) {
+		t.Errorf("Unexpected colors %q", page.Quoted)
+	}
+
+	var bad struct {
+		Regexp []string          `sq:"td | text | split.regexp(x{2,1})"`
+		Scalar int               `sq:"td | text | split(,)"`
+		Map    map[string]string `sq:"td | text | split(,)"`
+		Named  []string          `sq:"td | text | regexp.named((?P<a>a))"`
+	}
+	if errs := Validate(bad); len(errs) != 4 || !errors.Is(errs[0], ErrInvalidRegexp) || !errors.Is(errs[1], ErrBadTag) || !errors.Is(errs[2], ErrBadTag) || !errors.Is(errs[3], ErrBadTag) {
+		t.Errorf("Unexpected errors %q", errs)
+	}
+
+	// elements of kinds that cannot be loaded fail
+	// without converting their values
+	var kinds struct {
+		Chans   []chan int  `sq:"td.sizes | text | regexp.all(\\d)"`
+		Complex []complex64 `sq:"td.colors | text | split(,)"`
+	}
+	errs := Scrape(&kinds, strings.NewReader(testHTML))
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %q", errs)
+	}
+	for i, field := range []string{"Chans[]", "Complex[]"} {
+		var te *TagError
+		if !errors.As(errs[i], &te) || te.Field != field || !errors.Is(errs[i], ErrInvalidKind) {
+			t.Errorf("Expected %q at %s, got %q", ErrInvalidKind, field, errs[i])
+		}
+	}
+
+}
+
+func TestNumber(t *testing.T) {
+
+	tests := []struct {
+		locale, input, output string
+	}{
+		{"en", "1,234", "1234"},
+		{"en", "1,234.50", "1234.5"},
+		{"en", "$19.99", "19.99"},
+		{"en", "USD 1,000", "1000"},
+		{"en", "(1,234.56)", "-1234.56"},
+		{"en", "-12", "-12"},
+		{"en", "−12", "-12"},
+		{"en", ".5", "0.5"},
+		{"en", "12k", "12000"},
+		{"en", "1.5M", "1500000"},
+		{"en", "$2.25 bn", "2250000000"},
+		{"en", "45%", "0.45"},
+		{"en", "0.5%", "0.005"},
+		{"en", "1,23,456", "123456"},
+		{"en", "007", "7"},
+		{"en", "1 234", "1234"},
+		{"de", "1.234,56", "1234.56"},
+		{"de", "1.234,56 €", "1234.56"},
+		{"de", "-0,5", "-0.5"},
+		{"fr", "1 234,5", "1234.5"},
+		{"de-CH", "1'234.50", "1234.5"},
+		{"pt_BR", "R$ 1.234,00", "1234"},
+		{"en", "1,5", ""},
+		{"en", "1.234.567", ""},
+		{"en", "12 to 15", ""},
+		{"en", "n/a", ""},
+		{"en", "(12", ""},
+		{"de", "1,234,5", ""},
+		{"en", "Page 3", ""},
+		{"en", "5 m", ""},
+		{"en", "2 t", ""},
+		{"en", "5 k", ""},
+		{"en", "3 items", ""},
+		{"en", "10 EUR", "10"},
+		{"de-CH", "Fr. 12", "12"},
+	}
+
+	for _, test := range tests {
+		loc, _ := findLocale(test.locale)
+		output, err := loc.parseNumber(test.input)
+		if test.output == "" {
+			if !errors.Is(err, ErrNotANumber) {
+				t.Errorf("%s %q: expected %q, got %q, %v", test.locale, test.input, ErrNotANumber, output, err)
+			}
+			continue
+		}
+		if err != nil || output != test.output {
+			t.Errorf("%s %q: expected %q, got %q, %v", test.locale, test.input, test.output, output, err)
+		}
+	}
+
+	const testHTML = `
+		<p class="de">1.234,5</p>
+		<p class="en">1,234.5</p>
+		<p class="views">12k</p>
+	`
+
+	var page struct {
+		Float  float64 `sq:"p.de | text"`
+		Int    int     `sq:"p.en | text | number(en)"`
+		Float2 float32 `sq:"p.en | text | number(en_US)"`
+		Views  uint    `sq:"p.views | text | number"`
+		Text   string  `sq:"p.de | text | number"`
+	}
+
+	s := New(WithLocale("de"))
+	errs := s.Scrape(&page, strings.NewReader(testHTML))
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "Int: ") || !errors.Is(errs[0], strconv.ErrSyntax) {
+		t.Errorf("Unexpected errors %q", errs)
+	}
+	if page.Float != 1234.5 || page.Float2 != 1234.5 || page.Views != 12000 || page.Text != "1234.5" {
+		t.Errorf("Unexpected %+v", page)
+	}
+
+	// floats in Go syntax are still read by strconv
+	var floats struct {
+		Exp float64 `sq:"p.exp | text"`
+		NaN float32 `sq:"p.nan | text"`
+		Inf float64 `sq:"p.inf | text"`
+		Int int     `sq:"p.exp | text"`
+	}
+	const floatHTML = `<p class="exp">1e5</p><p class="nan">NaN</p><p class="inf">-Inf</p>`
+	errs = s.Scrape(&floats, strings.NewReader(floatHTML))
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "Int: ") || !errors.Is(errs[0], ErrNotANumber) {
+		t.Errorf("Unexpected errors %q", errs)
+	}
+	if floats.Exp != 1e5 || !math.IsNaN(float64(floats.NaN)) || !math.IsInf(floats.Inf, -1) {
+		t.Errorf("Unexpected %+v", floats)
+	}
+
+	// numbers are parsed strictly without a locale
+	if errs := New().Scrape(&page, strings.NewReader(testHTML)); len(errs) != 3 || !errors.Is(errs[2], ErrNotANumber) {
+		t.Errorf("Unexpected errors %q", errs)
+	}
+
+	// a user's number() is kept whatever the option order
+	custom := func(_ *goquery.Selection, _, _ string) (interface{}, error) { return "7", nil }
+	if errs := New(WithLoadFunc("number", custom), WithLocale("de")).Scrape(&page, strings.NewReader(testHTML)); len(errs) > 0 || page.Views != 7 {
+		t.Errorf("Expected the custom number(), got %d, %q", page.Views, errs)
+	}
+
+	var bad struct {
+		Int   int        `sq:"p | text | number(xx)"`
+		Money Money      `sq:"p | text | number"`
+		Time  *time.Time `sq:"p | text | number"`
+	}
+	errs = Validate(bad)
+	if len(errs) != 3 || !errors.Is(errs[0], ErrUnknownLocale) {
+		t.Errorf("Expected %q, got %q", ErrUnknownLocale, errs)
+	}
+	for _, err := range errs[1:] {
+		var te *TagError
+		if !errors.As(err, &te) || te.Stage != "number" || !errors.Is(err, ErrBadTag) {
+			t.Errorf("Expected a number() tag error, got %q", err)
+		}
+	}
+
+	// text loaded for other types is a conversion error
+	var loaded struct {
+		Money Money `sq:"p.views | text | raw"`
+	}
+	raw := func(_ *goquery.Selection, s, _ string) (interface{}, error) { return s, nil }
+	errs = New(WithLoadFunc("raw", raw)).Scrape(&loaded, strings.NewReader(testHTML))
+	if len(errs) != 1 || !errors.Is(errs[0], ErrInvalidKind) {
+		t.Errorf("Expected %q, got %q", ErrInvalidKind, errs)
+	}
+
+}
+
+func TestMoney(t *testing.T) {
+
+	tests := []struct {
+		locale, input string
+		output        Money
+		err           error
+	}{
+		{"en", "US$19.99", Money{1999, "USD"}, nil},
+		{"en", "€ 5", Money{500, "EUR"}, nil},
+		{"en", "£1,234.5", Money{123450, "GBP"}, nil},
+		{"en", "1,000 JPY", Money{1000, "JPY"}, nil},
+		{"en", "$10 USD", Money{1000, "USD"}, nil},
+		{"en", "($2.50)", Money{-250, "USD"}, ErrAmbiguousCurrency},
+		{"en-US", "($2.50)", Money{-250, "USD"}, nil},
+		{"en-CA", "$1.2k", Money{120000, "CAD"}, nil},
+		{"de", "1.234,56 €", Money{123456, "EUR"}, nil},
+		{"de-CH", "Fr. 1'250.–", Money{}, ErrNotANumber},
+		{"de-CH", "CHF 1'250.50", Money{125050, "CHF"}, nil},
+		{"sv", "99,50 kr", Money{9950, "SEK"}, nil},
+		{"pt-BR", "R$ 1.234,00", Money{123400, "BRL"}, nil},
+		{"ja", "¥1,200", Money{1200, "JPY"}, nil},
+		{"en", "¥1,200", Money{}, ErrAmbiguousCurrency},
+		{"en", "$10 EUR", Money{}, ErrAmbiguousCurrency},
+		{"en", "19.99", Money{}, ErrNoCurrency},
+		{"en", "$19.999 USD", Money{}, ErrNotANumber},
+		{"en", "Skr 10", Money{}, ErrNoCurrency},
+	}
+
+	for _, test := range tests {
+		loc, _ := findLocale(test.locale)
+		output, err := loc.parseMoney(test.input)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s %q: expected %q, got %v, %v", test.locale, test.input, test.err, output, err)
+			}
+			continue
+		}
+		if err != nil || output != test.output {
+			t.Errorf("%s %q: expected %v, got %v, %v", test.locale, test.input, test.output, output, err)
+		}
+	}
+
+	if s := (Money{-5, "USD"}).String(); s != "USD -0.05" {
+		t.Errorf("Expected USD -0.05, got %q", s)
+	}
+	if s := (Money{1200, "JPY"}).Decimal(); s != "1200" {
+		t.Errorf("Expected 1200, got %q", s)
+	}
+
+	// the documented examples
+	const docHTML = `<p>US$19.99</p><p>€ 5</p><p>CHF 12</p>`
+	var doc struct {
+		Prices []Money `sq:"p | text"`
+	}
+	if errs := Scrape(&doc, strings.NewReader(docHTML)); len(errs) > 0 || !reflect.DeepEqual(doc.Prices, []Money{{1999, "USD"}, {500, "EUR"}, {1200, "CHF"}}) {
+		t.Errorf("Unexpected %v, %q", doc.Prices, errs)
+	}
+
+	const testHTML = `
+		<p class="usd">$19.99</p>
+		<p class="eur">1.234,50 €</p>
+	`
+
+	var page struct {
+		USD *Money `sq:"p.usd | text"`
+		EUR Money  `sq:"p.eur | text"`
+	}
+
+	errs := Scrape(&page, strings.NewReader(testHTML))
+	if len(errs) != 2 || !errors.Is(errs[0], ErrAmbiguousCurrency) || !errors.Is(errs[1], ErrNotANumber) {
+		t.Errorf("Unexpected errors %q", errs)
+	}
+	page.USD = nil
+	if errs := New(WithLocale("en-US")).Scrape(&page, strings.NewReader(testHTML)); len(errs) != 1 || !errors.Is(errs[0], ErrNotANumber) {
+		t.Errorf("Unexpected errors %q", errs)
+	}
+	if page.USD == nil || *page.USD != (Money{1999, "USD"}) {
+		t.Errorf("Unexpected %v", page.USD)
+	}
+	page.USD = nil
+	if errs := New(WithLocale("de")).Scrape(&page, strings.NewReader(testHTML)); len(errs) != 1 || !errors.Is(errs[0], ErrAmbiguousCurrency) {
+		t.Errorf("Unexpected errors %q", errs)
+	}
+	if page.USD != nil || page.EUR != (Money{123450, "EUR"}) {
+		t.Errorf("Unexpected %v %v", page.USD, page.EUR)
+	}
+
+	// WithLocale leaves a user's money loader alone
+	type price string
+	var custom struct {
+		Price price `sq:"p.usd | text"`
+	}
+	isPrice := func(t reflect.Type) bool { return t.Name() == "price" }
+	load := func(_ *goquery.Selection, s string) (interface{}, error) { return price(s), nil }
+	if errs := New(WithTypeLoader("money", isPrice, load), WithLocale("en-US")).Scrape(&custom, strings.NewReader(testHTML)); len(errs) > 0 || custom.Price != "$19.99" {
+		t.Errorf("Expected the custom money loader, got %q, %q", custom.Price, errs)
+	}
+
+}
+
 func TestTime(t *testing.T) {
 
 	f := loadFuncs["time"]
@@ -67,6 +465,13 @@ func TestTime(t *testing.T) {
 	}{
 		{"2006", "2006", time.Date(2006, 1, 1, 0, 0, 0, 0, time.UTC)},
 		{"2016 05 23", "2006 01 02", time.Date(2016, 5, 23, 0, 0, 0, 0, time.UTC)},
+		{"23/05/2016", "2006-01-02 | 02/01/2006", time.Date(2016, 5, 23, 0, 0, 0, 0, time.UTC)},
+		{"2016-05-23T10:30:00.5+02:00", "", time.Date(2016, 5, 23, 8, 30, 0, 5e8, time.UTC)},
+		{"Mon, 23 May 2016 10:30:00 +0200", "", time.Date(2016, 5, 23, 8, 30, 0, 0, time.UTC)},
+		{"2016-05-23 10:30:00", "", time.Date(2016, 5, 23, 10, 30, 0, 0, time.UTC)},
+		{"1464000000", "", time.Date(2016, 5, 23, 10, 40, 0, 0, time.UTC)},
+		{"1464000000.25", "", time.Date(2016, 5, 23, 10, 40, 0, 25e7, time.UTC)},
+		{"1464000000123", "", time.Date(2016, 5, 23, 10, 40, 0, 123e6, time.UTC)},
 	}
 
 	for _, test := range tests {
@@ -74,9 +479,211 @@ func TestTime(t *testing.T) {
 		if err != nil {
 			t.Error(err)
 		}
-		if ouput.(time.Time) != test.output {
+		if !ouput.(time.Time).Equal(test.output) {
 			t.Errorf("Expected %v, got %v", test.output, ouput)
 		}
 	}
 
+	// the first layout's error is reported
+	if _, err := f(nil, "May 2016", "2006 | 01/2006"); err == nil || !strings.Contains(err.Error(), `"2006"`) {
+		t.Errorf("Expected a 2006 layout error, got %v", err)
+	}
+	if _, err := f(nil, "12345", ""); !errors.Is(err, ErrUnknownTimeFormat) {
+		t.Errorf("Expected %q, got %v", ErrUnknownTimeFormat, err)
+	}
+
+	const testHTML = `
+		<p class="local">2016-05-23 10:30</p>
+		<p class="rfc">Mon, 23 May 2016 10:30:00 GMT</p>
+		<p class="epoch">1464000000</p>
+		<p class="de">Montag, 23. März 2016</p>
+	`
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type page struct {
+		Local   time.Time   `sq:"p.local | text | time(2006-01-02 15:04), in(America/New_York)"`
+		Default time.Time   `sq:"p.local | text | time(2006-01-02 | 2006-01-02 15:04)"`
+		Detect  time.Time   `sq:"p.local | text | time()"`
+		RFC     time.Time   `sq:"p.rfc | text"`
+		Epoch   *time.Time  `sq:"p.epoch | text, in(Asia/Tokyo)"`
+		Dates   []time.Time `sq:"p.local, p.epoch | text | time(), in(America/New_York)"`
+	}
+
+	var p page
+	if errs := Scrape(&p, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if want := time.Date(2016, 5, 23, 10, 30, 0, 0, ny); !p.Local.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, p.Local)
+	}
+	if want := time.Date(2016, 5, 23, 10, 30, 0, 0, time.UTC); p.Default != want || p.Detect.Location() != time.UTC || !p.Detect.Equal(want) {
+		t.Errorf("Expected %v, got %v and %v", want, p.Default, p.Detect)
+	}
+	if want := time.Date(2016, 5, 23, 10, 30, 0, 0, time.UTC); !p.RFC.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, p.RFC)
+	}
+	if p.Epoch == nil || p.Epoch.Unix() != 1464000000 || p.Epoch.Location().String() != "Asia/Tokyo" {
+		t.Errorf("Expected 1464000000 in Asia/Tokyo, got %v", p.Epoch)
+	}
+	if len(p.Dates) != 2 || !p.Dates[0].Equal(time.Date(2016, 5, 23, 10, 30, 0, 0, ny)) || p.Dates[1].Unix() != 1464000000 {
+		t.Errorf("Unexpected dates %v", p.Dates)
+	}
+
+	// the scraper's location and locale apply to
+	// times without a zone and month and day names
+	var local struct {
+		Local time.Time `sq:"p.local | text"`
+		Zoned time.Time `sq:"p.local | text, in(America/New_York)"`
+		RFC   time.Time `sq:"p.rfc | text"`
+	}
+	s := New(WithLocation(paris))
+	if errs := s.Scrape(&local, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if !local.Local.Equal(time.Date(2016, 5, 23, 10, 30, 0, 0, paris)) || !local.Zoned.Equal(time.Date(2016, 5, 23, 10, 30, 0, 0, ny)) || !local.RFC.Equal(p.RFC) {
+		t.Errorf("Unexpected times %+v", local)
+	}
+
+	// user loaders named time are left alone
+	type stamp string
+	var custom struct {
+		Stamp stamp `sq:"p.local | text"`
+	}
+	isStamp := func(t reflect.Type) bool { return t.Name() == "stamp" }
+	loadStamp := func(_ *goquery.Selection, s string) (interface{}, error) { return stamp(s), nil }
+	if errs := New(WithTypeLoader("time", isStamp, loadStamp), WithLocation(paris)).Scrape(&custom, strings.NewReader(testHTML)); len(errs) > 0 || custom.Stamp != "2016-05-23 10:30" {
+		t.Errorf("Expected the custom time loader, got %q, %q", custom.Stamp, errs)
+	}
+
+	var de struct {
+		Date time.Time `sq:"p.de | text | time(Monday, 2. January 2006)"`
+	}
+	if errs := New(WithLocale("de"), WithLocation(paris)).Scrape(&de, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if want := time.Date(2016, 3, 23, 0, 0, 0, 0, paris); !de.Date.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, de.Date)
+	}
+
+	names := []struct {
+		locale, input, layout string
+		output                time.Time
+	}{
+		{"fr", "23 juin 2016", "2 Jan 2006", time.Date(2016, 6, 23, 0, 0, 0, 0, time.UTC)},
+		{"fr-CH", "lundi 23 mai 2016", "Monday 2 January 2006", time.Date(2016, 5, 23, 0, 0, 0, 0, time.UTC)},
+		{"es", "23 mar 2016", "2 Jan 2006", time.Date(2016, 3, 23, 0, 0, 0, 0, time.UTC)},
+		{"pt", "segunda-feira, 23 de maio de 2016", "Monday, 2 de January de 2006", time.Date(2016, 5, 23, 0, 0, 0, 0, time.UTC)},
+		{"nl", "23 mrt. 2016", "2 Jan. 2006", time.Date(2016, 3, 23, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range names {
+		output, err := New(WithLocale(test.locale)).loadFuncs["time"](nil, test.input, test.layout)
+		if err != nil {
+			t.Errorf("%s: %v", test.locale, err)
+			continue
+		}
+		if !output.(time.Time).Equal(test.output) {
+			t.Errorf("%s: Expected %v, got %v", test.locale, test.output, output)
+		}
+	}
+
+	var bad struct {
+		Zone   time.Time `sq:"p.local | text, in(Nowhere/Zone)"`
+		Empty  time.Time `sq:"p.local | text | time(2006 |), in(UTC)"`
+		Kind   string    `sq:"p.local | text, in(UTC)"`
+		Loader time.Time `sq:"p.local | text | number(), in(UTC)"`
+	}
+	errs := Validate(bad)
+	if len(errs) != 4 {
+		t.Fatalf("Expected 4 errors, got %q", errs)
+	}
+	for i, stage := range []string{"in", "time", "in", "in"} {
+		var te *TagError
+		if !errors.As(errs[i], &te) || te.Stage != stage || !errors.Is(errs[i], ErrBadTag) {
+			t.Errorf("Expected a %s tag error, got %q", stage, errs[i])
+		}
+	}
+
+}
+
+func TestTypeLoaders(t *testing.T) {
+
+	const testHTML = `<p>a</p>`
+
+	type label string
+	type page struct {
+		Label    label   `sq:"p | text"`
+		Labels   []label `sq:"p | text, loader(lower)"`
+		Override label   `sq:"p | text, loader(lower)"`
+	}
+
+	isLabel := func(t reflect.Type) bool { return t.Name() == "label" }
+	constant := func(s string) func(*goquery.Selection, string) (interface{}, error) {
+		return func(*goquery.Selection, string) (interface{}, error) { return label(s), nil }
+	}
+
+	s := New(WithTypeLoader("lower", isLabel, constant("lower")))
+	s.RegisterTypeLoaderPriority("high", 10, isLabel, constant("high"))
+	s.RegisterTypeLoader("same", isLabel, constant("same"))
+
+	names := s.TypeLoaders()
+	if len(names) < 3 || names[0] != "high" || names[1] != "same" || names[2] != "lower" {
+		t.Errorf("Expected high, same, lower first, got %q", names)
+	}
+	// built-ins lose to user loaders of equal priority
+	if names[len(names)-1] != "money" {
+		t.Errorf("Expected money last, got %q", names)
+	}
+
+	// resolution is stable from run to run
+	for i := 0; i < 20; i++ {
+		s.RegisterTypeLoaderPriority("high", 10, isLabel, constant("high"))
+		var p page
+		if errs := s.Scrape(&p, strings.NewReader(testHTML)); len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if p.Label != "high" || p.Override != "lower" || len(p.Labels) != 1 || p.Labels[0] != "lower" {
+			t.Fatalf("Expected high and lower, got %+v", p)
+		}
+	}
+
+	if !s.UnregisterTypeLoader("high") || s.UnregisterTypeLoader("high") {
+		t.Error("Expected high to be unregistered once")
+	}
+	var p page
+	if errs := s.Scrape(&p, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Error(errs)
+	}
+	if p.Label != "same" {
+		t.Errorf("Expected %q, got %q", "same", p.Label)
+	}
+
+	// the default scraper is untouched
+	errs := Validate(page{})
+	if len(errs) != 2 || !errors.Is(errs[0], ErrUnknownTypeLoader) {
+		t.Errorf("Expected %q, got %q", ErrUnknownTypeLoader, errs)
+	}
+
+	var bad struct {
+		Kind label  `sq:"p | text, loader(url)"`
+		Both string `sq:"p | text | time(2006), loader(url)"`
+	}
+	errs = s.Validate(bad)
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %q", errs)
+	}
+	for _, err := range errs {
+		var te *TagError
+		if !errors.As(err, &te) || te.Stage != "loader" || !errors.Is(err, ErrBadTag) {
+			t.Errorf("Expected a loader tag error, got %q", err)
+		}
+	}
+
 }
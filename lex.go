@@ -0,0 +1,327 @@
+package sq
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The sq tag grammar:
+//
+//	tag      = pipeline { "||" pipeline } { "," option }
+//	pipeline = selector { "|" call }
+//	option   = call
+//	call     = name [ "(" [ arg ] ")" ]
+//	arg      = quoted | raw
+//
+// Selectors may contain top level commas, so a trailing comma
+// separated part is only an option if it is a call to a known
+// option name.  Pipelines separated by "||" are alternatives
+// tried in order.
+//
+// Pipes, commas and parentheses nested inside (), [] or {} or
+// inside quotes are not syntax, so `regexp(a | b)` and
+// `append(" | ")` are single calls.  A backslash stops the
+// following character from being treated as syntax but is kept,
+// so regexps like `regexp(\(\d+\))` pass through unchanged.
+// Funcs are passed the raw text between the parentheses, commas
+// included.  Quoted args may use " or ' and support \" \' and
+// \\ escapes; quotes only open an arg when they are its first
+// character, and are only stripped from an arg that is wholly
+// quoted and has a pipe or comma, so existing tags keep theirs.
+
+type (
+	// segment is a piece of tag text and the
+	// 1-based column it starts at.
+	segment struct {
+		text string
+		col  int
+	}
+
+	// call is a parsed accessor or func stage.
+	call struct {
+		name string
+		// args is the raw text between the parentheses,
+		// or the unquoted arg if its quotes protect a
+		// pipe or comma.
+		args    string
+		hasArgs bool
+		col     int
+	}
+)
+
+// syntaxError reports a problem at col of the tag.
+func syntaxError(col int, format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %s at column %d", ErrBadTag, fmt.Sprintf(format, a...), col)
+}
+
+// lexTag splits a tag into the stages of its alternative pipelines
+// and its trailing options.  isOption reports whether name is a
+// known option.
+func lexTag(tag string, isOption func(name string) bool) ([][]segment, []call, error) {
+
+	pipes, commas, err := scanTag(tag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		opts []call
+		end  = len(tag)
+	)
+	for i := len(commas) - 1; i >= 0; i-- {
+		c, err := parseCall(segment{text: tag[commas[i]+1 : end], col: commas[i] + 2})
+		if err != nil || !isOption(c.name) {
+			break
+		}
+		opts = append([]call{c}, opts...)
+		end = commas[i]
+	}
+
+	var (
+		alts  [][]segment
+		segs  []segment
+		start = 0
+	)
+	for j := 0; j < len(pipes) && pipes[j] < end; j++ {
+		i := pipes[j]
+		segs = append(segs, segment{text: tag[start:i], col: start + 1})
+		start = i + 1
+		if j+1 < len(pipes) && pipes[j+1] == i+1 {
+			// "||" starts an alternative pipeline
+			alts = append(alts, segs)
+			segs, start = nil, i+2
+			j++
+		}
+	}
+	segs = append(segs, segment{text: tag[start:end], col: start + 1})
+	alts = append(alts, segs)
+
+	return alts, opts, nil
+
+}
+
+// scanTag returns the indexes of the top level pipes and
+// commas in tag.
+func scanTag(tag string) ([]int, []int, error) {
+
+	var (
+		pipes  []int
+		commas []int
+		stack  []int
+		// the selector is the first stage of a pipeline
+		selector = true
+		// in a call, quotes only open an arg
+		argStart = false
+	)
+
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+
+		if c == '\\' {
+			i++
+			argStart = false
+			continue
+		}
+
+		bracket := len(stack) > 0 && tag[stack[len(stack)-1]] == '['
+
+		if (c == '"' || c == '\'') && selector {
+			j, err := skipQuoted(tag, i)
+			if err != nil {
+				return nil, nil, err
+			}
+			i = j
+			continue
+		}
+		if argStart {
+			if j, ok := quotedArg(tag, i); ok {
+				i = j
+				argStart = false
+				continue
+			}
+		}
+
+		switch {
+		case bracket && c != ']':
+		case c == '(' || c == '[' || c == '{':
+			stack = append(stack, i)
+		case c == ')' || c == ']' || c == '}':
+			if len(stack) == 0 || tag[stack[len(stack)-1]] != opener(c) {
+				return nil, nil, syntaxError(i+1, "unexpected %q", c)
+			}
+			stack = stack[:len(stack)-1]
+		case c == '|' && len(stack) == 0:
+			pipes = append(pipes, i)
+			selector = false
+			if i+1 < len(tag) && tag[i+1] == '|' {
+				// an alternative pipeline starts with a selector
+				pipes = append(pipes, i+1)
+				selector = true
+				i++
+			}
+		case c == ',' && len(stack) == 0:
+			commas = append(commas, i)
+		}
+
+		switch {
+		case c == ' ' || c == '\t':
+			// whitespace does not end an arg start
+		case !selector && len(stack) > 0 && !bracket && (c == '(' || c == ','):
+			argStart = true
+		default:
+			argStart = false
+		}
+	}
+
+	if len(stack) > 0 {
+		i := stack[len(stack)-1]
+		return nil, nil, syntaxError(i+1, "unclosed %q", tag[i])
+	}
+
+	return pipes, commas, nil
+
+}
+
+func opener(c byte) byte {
+	switch c {
+	case ')':
+		return '('
+	case ']':
+		return '['
+	}
+	return '{'
+}
+
+// skipQuoted returns the index of the quote closing the
+// quoted string starting at i.
+func skipQuoted(s string, i int) (int, error) {
+	q := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case q:
+			return j, nil
+		}
+	}
+	return 0, syntaxError(i+1, "unterminated quote")
+}
+
+// quotedArg returns the index of the quote closing the quoted
+// arg starting at i.  Quotes that are unterminated or followed
+// by more of the arg are text.
+func quotedArg(s string, i int) (int, bool) {
+	if s[i] != '"' && s[i] != '\'' {
+		return 0, false
+	}
+	j, err := skipQuoted(s, i)
+	if err != nil {
+		return 0, false
+	}
+	if rest := strings.TrimLeft(s[j+1:], " \t"); rest == "" || rest[0] != ',' && rest[0] != ')' {
+		return 0, false
+	}
+	return j, true
+}
+
+// unquote strips the quotes from s and resolves
+// \", \' and \\ escapes.  Other backslashes are kept.
+func unquote(s string) string {
+	q := s[0]
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == q || s[i+1] == '\\') {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// parseCall parses a name(arg, ...) stage.
+func parseCall(seg segment) (call, error) {
+
+	text := strings.TrimSpace(seg.text)
+	col := seg.col + strings.Index(seg.text, text)
+	c := call{col: col}
+
+	if text == "" {
+		return c, syntaxError(seg.col, "empty stage")
+	}
+
+	i := strings.IndexByte(text, '(')
+	if i == -1 {
+		c.name = text
+	} else {
+		c.name = strings.TrimSpace(text[:i])
+	}
+	for j, r := range c.name {
+		if !isNameRune(r) {
+			return c, syntaxError(col+j, "unexpected %q in name %q", r, c.name)
+		}
+	}
+	if c.name == "" {
+		return c, syntaxError(col, "missing name")
+	}
+	if i == -1 {
+		return c, nil
+	}
+
+	// scanTag has already balanced the parens, so the
+	// one closing the arg list is the first at depth zero.
+	var (
+		stack []byte
+		end   = -1
+		from  = i + 1
+	)
+	for j := i; j < len(text) && end == -1; j++ {
+		ch := text[j]
+		if len(stack) == 1 && strings.TrimSpace(text[from:j]) == "" {
+			if k, ok := quotedArg(text, j); ok {
+				j = k
+				continue
+			}
+		}
+		bracket := len(stack) > 0 && stack[len(stack)-1] == '['
+		switch {
+		case ch == '\\':
+			j++
+		case bracket && ch != ']':
+		case ch == '(' || ch == '[' || ch == '{':
+			stack = append(stack, ch)
+		case ch == ')' || ch == ']' || ch == '}':
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				end = j
+			}
+		case ch == ',' && len(stack) == 1:
+			from = j + 1
+		}
+	}
+	if rest := text[end+1:]; rest != "" {
+		return c, syntaxError(col+end+1, "unexpected %q after %q", rest, text[:end+1])
+	}
+
+	c.args = text[i+1 : end]
+	arg := strings.TrimSpace(c.args)
+	c.hasArgs = arg != ""
+	// quotes are only syntax when they protect the pipes
+	// or commas of a whole arg, so tags such as
+	// regexp("(.*)") keep theirs
+	if arg != "" {
+		if k, ok := quotedArg(arg+")", 0); ok && k == len(arg)-1 {
+			if s := unquote(arg); strings.ContainsAny(s, "|,") {
+				c.args = s
+			}
+		}
+	}
+
+	return c, nil
+
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || r == '.' || r == '-' ||
+		(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
@@ -0,0 +1,262 @@
+package sq
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	ErrNoCurrency        = errors.New("no currency")
+	ErrAmbiguousCurrency = errors.New("ambiguous currency")
+)
+
+// Money is an amount in a currency, loaded from text such as
+// "US$19.99", "€ 5" or "CHF 12".  Text such as "$19.99", whose
+// symbol is shared by several currencies, or "1.234,50 €", with a
+// decimal comma, needs a Scraper with a locale, ie. WithLocale("en-US")
+// or WithLocale("de").
+type Money struct {
+	// Amount is in the minor units of the currency, ie. cents.
+	Amount int64
+	// Currency is the ISO 4217 code, ie. USD.
+	Currency string
+}
+
+// currencies are the ISO 4217 codes sq recognises and the number
+// of decimals of their minor units.
+var currencies = map[string]int{
+	"AED": 2, "ARS": 2, "AUD": 2, "BGN": 2, "BHD": 3, "BRL": 2,
+	"CAD": 2, "CHF": 2, "CLP": 0, "CNY": 2, "COP": 2, "CZK": 2,
+	"DKK": 2, "EGP": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2,
+	"IDR": 2, "ILS": 2, "INR": 2, "ISK": 0, "JOD": 3, "JPY": 0,
+	"KRW": 0, "KWD": 3, "MXN": 2, "MYR": 2, "NGN": 2, "NOK": 2,
+	"NZD": 2, "OMR": 3, "PHP": 2, "PKR": 2, "PLN": 2, "RON": 2,
+	"RUB": 2, "SAR": 2, "SEK": 2, "SGD": 2, "THB": 2, "TND": 3,
+	"TRY": 2, "TWD": 2, "UAH": 2, "USD": 2, "VND": 0, "ZAR": 2,
+}
+
+// currencySymbols are the currencies each symbol may stand for.
+// Symbols of several currencies are resolved by the locale.
+var currencySymbols = map[string][]string{
+	"$":   {"USD", "CAD", "AUD", "NZD", "MXN", "HKD", "SGD", "TWD", "ARS", "CLP", "COP"},
+	"US$": {"USD"}, "CA$": {"CAD"}, "C$": {"CAD"}, "A$": {"AUD"}, "AU$": {"AUD"},
+	"NZ$": {"NZD"}, "HK$": {"HKD"}, "S$": {"SGD"}, "MX$": {"MXN"}, "NT$": {"TWD"},
+	"R$": {"BRL"},
+	"¥":  {"JPY", "CNY"},
+	"kr": {"SEK", "NOK", "DKK", "ISK"},
+	"€":  {"EUR"}, "£": {"GBP"}, "₹": {"INR"}, "₩": {"KRW"}, "₽": {"RUB"},
+	"₺": {"TRY"}, "₪": {"ILS"}, "₫": {"VND"}, "฿": {"THB"}, "₱": {"PHP"},
+	"₴": {"UAH"}, "₦": {"NGN"}, "zł": {"PLN"}, "Kč": {"CZK"}, "Ft": {"HUF"},
+	"Fr.": {"CHF"}, "lei": {"RON"}, "Rp": {"IDR"}, "RM": {"MYR"},
+}
+
+// symbols are the keys of currencySymbols, longest first so US$
+// is matched before $.
+var symbols = func() []string {
+	var symbols []string
+	for s := range currencySymbols {
+		symbols = append(symbols, s)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if len(symbols[i]) != len(symbols[j]) {
+			return len(symbols[i]) > len(symbols[j])
+		}
+		return symbols[i] < symbols[j]
+	})
+	return symbols
+}()
+
+// regionCurrencies resolve ambiguous symbols by the region of the
+// locale, or by its language if it has no region.
+var regionCurrencies = map[string]string{
+	"us": "USD", "ca": "CAD", "au": "AUD", "nz": "NZD", "mx": "MXN",
+	"hk": "HKD", "sg": "SGD", "tw": "TWD", "ar": "ARS", "cl": "CLP",
+	"co": "COP", "jp": "JPY", "cn": "CNY", "se": "SEK", "no": "NOK",
+	"dk": "DKK", "is": "ISK",
+	"ja": "JPY", "zh": "CNY", "sv": "SEK", "nb": "NOK", "nn": "NOK",
+	"da": "DKK",
+}
+
+var moneyType = reflect.TypeOf(Money{})
+
+// moneyLoader returns the load func of the Money type loader, which
+// reads amounts as written in loc, or in English if loc is nil.
+func moneyLoader(loc *locale) func(sel *goquery.Selection, s string) (interface{}, error) {
+	if loc == nil {
+		loc = &locale{name: "en", decimal: '.'}
+	}
+	return func(_ *goquery.Selection, s string) (interface{}, error) {
+		return loc.parseMoney(s)
+	}
+}
+
+// parseMoney reads the amount and currency in s.  Currencies are
+// found by ISO 4217 code or symbol, and symbols shared by several
+// currencies, ie. $, are reported unless the locale settles them.
+func (l *locale) parseMoney(s string) (Money, error) {
+
+	code, rest, err := l.findCurrency(s)
+	if err != nil {
+		return Money{}, err
+	}
+	n, err := l.parseNumber(rest)
+	if err != nil {
+		return Money{}, err
+	}
+
+	neg := strings.HasPrefix(n, "-")
+	whole, frac, _ := strings.Cut(strings.TrimPrefix(n, "-"), ".")
+	digits := currencies[code]
+	if len(frac) > digits {
+		return Money{}, fmt.Errorf("%w: %s amounts have %d decimals, not %d", ErrNotANumber, code, digits, len(frac))
+	}
+	amount, err := strconv.ParseInt(whole+frac+strings.Repeat("0", digits-len(frac)), 10, 64)
+	if err != nil {
+		return Money{}, err
+	}
+	if neg {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: code}, nil
+
+}
+
+// findCurrency returns the currency in s and s without it.  Codes
+// and symbols may be repeated, ie. "$10 USD", as long as they agree.
+func (l *locale) findCurrency(s string) (string, string, error) {
+
+	var (
+		b     strings.Builder
+		found []string
+		// codes are those every match may stand for
+		codes []string
+	)
+
+	for i := 0; i < len(s); {
+		token, cands := currencyAt(s, i)
+		if token == "" {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			b.WriteString(s[i : i+size])
+			i += size
+			continue
+		}
+		found = append(found, token)
+		if codes == nil {
+			codes = cands
+		} else if codes = intersect(codes, cands); len(codes) == 0 {
+			return "", "", fmt.Errorf("%w: %s", ErrAmbiguousCurrency, strings.Join(found, " and "))
+		}
+		b.WriteByte(' ')
+		i += len(token)
+	}
+
+	switch {
+	case found == nil:
+		return "", "", fmt.Errorf("%w in %q", ErrNoCurrency, s)
+	case len(codes) == 1:
+		return codes[0], b.String(), nil
+	}
+	lang, region, _ := strings.Cut(l.name, "-")
+	for _, key := range []string{region, lang} {
+		if c, exists := regionCurrencies[key]; exists && contains(codes, c) {
+			return c, b.String(), nil
+		}
+	}
+	return "", "", fmt.Errorf("%w: %q may be %s", ErrAmbiguousCurrency, found[0], strings.Join(codes, ", "))
+
+}
+
+// currencyAt returns the ISO 4217 code or currency symbol at i, and
+// the currencies it may stand for.
+func currencyAt(s string, i int) (string, []string) {
+	if w := wordAt(s, i); len(w) == 3 && strings.ToUpper(w) == w {
+		if _, exists := currencies[w]; exists {
+			return w, []string{w}
+		}
+	}
+	for _, sym := range symbols {
+		if strings.HasPrefix(s[i:], sym) && isCurrencyToken(s, i, sym) {
+			return sym, currencySymbols[sym]
+		}
+	}
+	return "", nil
+}
+
+func intersect(a, b []string) []string {
+	var both []string
+	for _, s := range a {
+		if contains(b, s) {
+			both = append(both, s)
+		}
+	}
+	return both
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// wordAt returns the letters starting at i if they start a word.
+func wordAt(s string, i int) string {
+	if r, _ := utf8.DecodeLastRuneInString(s[:i]); i > 0 && unicode.IsLetter(r) {
+		return ""
+	}
+	end := strings.IndexFunc(s[i:], func(r rune) bool { return !unicode.IsLetter(r) })
+	if end == -1 {
+		return s[i:]
+	}
+	return s[i : i+end]
+}
+
+// isCurrencyToken reports whether sym at i stands alone, rather than
+// being part of a word, when it starts or ends with a letter.
+func isCurrencyToken(s string, i int, sym string) bool {
+	first, _ := utf8.DecodeRuneInString(sym)
+	if before, _ := utf8.DecodeLastRuneInString(s[:i]); i > 0 && unicode.IsLetter(first) && unicode.IsLetter(before) {
+		return false
+	}
+	last, _ := utf8.DecodeLastRuneInString(sym)
+	if after, _ := utf8.DecodeRuneInString(s[i+len(sym):]); i+len(sym) < len(s) && unicode.IsLetter(last) && unicode.IsLetter(after) {
+		return false
+	}
+	return true
+}
+
+// Decimal returns the amount as a decimal, ie. "-19.99".
+func (m Money) Decimal() string {
+	digits, exists := currencies[m.Currency]
+	if !exists {
+		digits = 2
+	}
+	n := strconv.FormatInt(m.Amount, 10)
+	neg := strings.HasPrefix(n, "-")
+	n = strings.TrimPrefix(n, "-")
+	if digits > 0 {
+		if len(n) <= digits {
+			n = strings.Repeat("0", digits-len(n)+1) + n
+		}
+		n = n[:len(n)-digits] + "." + n[len(n)-digits:]
+	}
+	if neg {
+		n = "-" + n
+	}
+	return n
+}
+
+// String returns the currency and amount, ie. "USD 19.99".
+func (m Money) String() string {
+	return m.Currency + " " + m.Decimal()
+}
@@ -0,0 +1,291 @@
+package sq
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	ErrUnknownLocale = errors.New("unknown locale")
+	ErrNotANumber    = errors.New("not a number")
+)
+
+type locale struct {
+	name string
+	// decimal separates the fraction, the other of '.'
+	// and ',' groups digits.
+	decimal rune
+}
+
+// locales are keyed by lower case language, or language and region
+// where the region writes numbers differently.
+var locales = map[string]rune{
+	"en": '.', "ja": '.', "zh": '.', "ko": '.', "he": '.', "th": '.',
+	"hi": '.', "ms": '.', "tl": '.', "ga": '.', "cy": '.',
+	"de": ',', "fr": ',', "es": ',', "it": ',', "nl": ',', "pt": ',',
+	"ru": ',', "pl": ',', "cs": ',', "sk": ',', "sv": ',', "da": ',',
+	"nb": ',', "nn": ',', "no": ',', "fi": ',', "tr": ',', "id": ',',
+	"uk": ',', "el": ',', "hu": ',', "ro": ',', "bg": ',', "hr": ',',
+	"sl": ',', "sr": ',', "lt": ',', "lv": ',', "et": ',', "vi": ',',
+	"ca": ',', "is": ',', "be": ',', "kk": ',', "eu": ',', "gl": ',',
+	"de-ch": '.', "de-li": '.', "it-ch": '.', "es-mx": '.', "es-us": '.',
+	"en-za": ',',
+}
+
+// multipliers are the suffixes scaling a number by a power of ten.
+// Letters more often standing for units, ie. m for metres, are left
+// out.
+var multipliers = map[string]int{
+	"k": 3, "K": 3,
+	"M": 6, "mn": 6, "MM": 6,
+	"B": 9, "bn": 9,
+	"tn": 12,
+}
+
+// findLocale returns the locale called name, ie. "de", "pt_BR" or
+// "de-CH", falling back to its language.
+func findLocale(name string) (*locale, bool) {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "_", "-"))
+	if dec, exists := locales[name]; exists {
+		return &locale{name: name, decimal: dec}, true
+	}
+	if lang, _, found := strings.Cut(name, "-"); found {
+		if dec, exists := locales[lang]; exists {
+			return &locale{name: name, decimal: dec}, true
+		}
+	}
+	return nil, false
+}
+
+func checkLocale(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return nil
+	}
+	if _, exists := findLocale(name); !exists {
+		return fmt.Errorf("%w: %q", ErrUnknownLocale, name)
+	}
+	return nil
+}
+
+// numberLoader returns the number load func, which reads numbers
+// written in def, or in English if def is nil, unless the tag names
+// another locale.
+func numberLoader(def *locale) LoadFunc {
+	return func(_ *goquery.Selection, s, name string) (interface{}, error) {
+		loc := def
+		if strings.TrimSpace(name) != "" {
+			l, exists := findLocale(name)
+			if !exists {
+				return nil, fmt.Errorf("%w: %q", ErrUnknownLocale, name)
+			}
+			loc = l
+		}
+		if loc == nil {
+			loc = &locale{name: "en", decimal: '.'}
+		}
+		return loc.parseNumber(s)
+	}
+}
+
+// parseNumber reads s as written in l and returns it in the form
+// strconv parses, ie. "(1.234,5 €)" is "-1234.5" in German.
+// Grouping by spaces and apostrophes, currency symbols and codes,
+// a leading sign or enclosing parentheses for negatives, a
+// multiplier suffix such as k or M and a trailing percent sign,
+// which divides by 100, are accepted.  Any other text is not.
+func (l *locale) parseNumber(s string) (string, error) {
+
+	s = strings.TrimSpace(s)
+
+	start := strings.IndexFunc(s, isASCIIDigit)
+	if start == -1 {
+		return "", ErrNotANumber
+	}
+	if strings.HasSuffix(s[:start], string(l.decimal)) {
+		start--
+	}
+	end := strings.LastIndexFunc(s, isASCIIDigit) + 1
+
+	neg, open, err := numberPrefix(s[:start])
+	if err != nil {
+		return "", err
+	}
+	shift, closed, err := numberSuffix(s[end:])
+	if err != nil {
+		return "", err
+	}
+	if open != closed {
+		return "", fmt.Errorf("%w: unbalanced parentheses", ErrNotANumber)
+	}
+
+	digits, point, err := l.numberDigits(s[start:end])
+	if err != nil {
+		return "", err
+	}
+
+	n := shiftPoint(digits, point+shift)
+	if (neg || open) && strings.Trim(n, "0.") != "" {
+		n = "-" + n
+	}
+	return n, nil
+
+}
+
+// numberPrefix reads the sign, opening parenthesis and currency
+// before a number.
+func numberPrefix(s string) (neg, open bool, err error) {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '-' || r == '\u2212':
+			if neg {
+				return false, false, fmt.Errorf("%w: repeated sign", ErrNotANumber)
+			}
+			neg = true
+		case r == '(':
+			open = true
+		case r == '+', unicode.IsSpace(r), unicode.Is(unicode.Sc, r):
+		default:
+			// letters are only accepted in currencies
+			token, _ := currencyAt(s, i)
+			if token == "" {
+				return false, false, fmt.Errorf("%w: unexpected %q", ErrNotANumber, r)
+			}
+			size = len(token)
+		}
+		i += size
+	}
+	return neg, open, nil
+}
+
+// numberSuffix reads the multiplier, percent sign, closing
+// parenthesis and currency after a number, and returns the power of
+// ten they scale it by.  Single letter multipliers must follow the
+// digits directly, so "5k" is 5000 but "5 k" is not a number.
+func numberSuffix(s string) (shift int, closed bool, err error) {
+	word := strings.TrimLeftFunc(s, unicode.IsSpace)
+	if i := strings.IndexFunc(word, func(r rune) bool { return !unicode.IsLetter(r) }); i > -1 {
+		word = word[:i]
+	}
+	if exp, exists := multipliers[word]; exists && (len(word) > 1 || strings.HasPrefix(s, word)) {
+		shift = exp
+		s = strings.Replace(s, word, "", 1)
+	}
+	percent := false
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '%':
+			if percent {
+				return 0, false, fmt.Errorf("%w: repeated %%", ErrNotANumber)
+			}
+			percent = true
+			shift -= 2
+		case r == ')':
+			closed = true
+		case unicode.IsSpace(r), unicode.Is(unicode.Sc, r):
+		default:
+			token, _ := currencyAt(s, i)
+			if token == "" {
+				return 0, false, fmt.Errorf("%w: unexpected %q", ErrNotANumber, r)
+			}
+			size = len(token)
+		}
+		i += size
+	}
+	return shift, closed, nil
+}
+
+// numberDigits returns the digits of s and the position of the
+// decimal point among them.  Digits are grouped by the separator
+// that is not l's decimal, spaces or apostrophes, in groups of three
+// after the first, or of two as in India.
+func (l *locale) numberDigits(s string) (string, int, error) {
+
+	var (
+		b      strings.Builder
+		groups []int
+		run    int
+		point  = -1
+	)
+
+	for _, r := range s {
+		switch {
+		case isASCIIDigit(r):
+			b.WriteRune(r)
+			run++
+		case r == l.decimal:
+			if point > -1 {
+				return "", 0, fmt.Errorf("%w: repeated decimal separator", ErrNotANumber)
+			}
+			point = b.Len()
+			groups = append(groups, run)
+			run = 0
+		case isGroupSeparator(r):
+			if point > -1 || run == 0 {
+				return "", 0, fmt.Errorf("%w: misplaced %q", ErrNotANumber, r)
+			}
+			groups = append(groups, run)
+			run = 0
+		default:
+			return "", 0, fmt.Errorf("%w: unexpected %q", ErrNotANumber, r)
+		}
+	}
+	if point == -1 {
+		point = b.Len()
+		groups = append(groups, run)
+	}
+
+	// groups holds the runs of the integer part
+	for i, g := range groups[1:] {
+		if g != 3 && (g != 2 || i == len(groups)-2) {
+			return "", 0, fmt.Errorf("%w: misplaced grouping in %q", ErrNotANumber, s)
+		}
+	}
+	if len(groups) > 1 && groups[0] > 3 {
+		return "", 0, fmt.Errorf("%w: misplaced grouping in %q", ErrNotANumber, s)
+	}
+
+	return b.String(), point, nil
+
+}
+
+// shiftPoint places the decimal point after point digits, padding
+// with zeros as needed, and trims insignificant zeros.
+func shiftPoint(digits string, point int) string {
+	switch {
+	case point <= 0:
+		digits = "0." + strings.Repeat("0", -point) + digits
+	case point >= len(digits):
+		digits += strings.Repeat("0", point-len(digits))
+	default:
+		digits = digits[:point] + "." + digits[point:]
+	}
+	if strings.Contains(digits, ".") {
+		digits = strings.TrimRight(strings.TrimRight(digits, "0"), ".")
+	}
+	if digits = strings.TrimLeft(digits, "0"); digits == "" || digits[0] == '.' {
+		digits = "0" + digits
+	}
+	return digits
+}
+
+func isASCIIDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// isGroupSeparator reports whether r groups digits in any locale.
+// Whichever of '.' and ',' is not the decimal separator is checked
+// before.
+func isGroupSeparator(r rune) bool {
+	switch r {
+	case '.', ',', ' ', '\u00a0', '\u202f', '\u2009', '\'', '’':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,688 @@
+package sq
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+type (
+	// Plan is the compiled form of a struct type.  Tags are
+	// parsed, funcs and type loaders resolved and regexps
+	// compiled once, so scraping only does selection and
+	// assignment.
+	Plan struct {
+		typ  reflect.Type
+		root *valuePlan
+		errs []error
+	}
+
+	structPlan struct {
+		fields []fieldPlan
+	}
+
+	fieldPlan struct {
+		index int
+		name  string
+		// err is a tag error reported on every scrape
+		err *TagError
+		val *valuePlan
+	}
+
+	valuePlan struct {
+		path *path
+		// typ is the dereferenced type of the value
+		typ    reflect.Type
+		loader *loader
+		// err is reported once the selector has matched,
+		// stage is the part of the tag at fault.
+		err   error
+		stage string
+		// preselected is set on collection elements, which
+		// were selected by the collection's selector.
+		preselected bool
+		// eager allocates pointers before selection.
+		eager bool
+		// attrs is set on maps and structs decoded from
+		// the attributes of their node.
+		attrs func(*goquery.Selection) []html.Attribute
+		st    *structPlan
+		// elem is the plan for collection elements
+		// and map values.
+		elem *valuePlan
+		key  *valuePlan
+		// locale reads the text of number values.
+		locale *locale
+		// split is set on values loaded from the
+		// values split out of their text.
+		split *splitter
+		// unique keys the elements of a slice
+		// deduplicated by unique().
+		unique func(v reflect.Value) (interface{}, bool)
+		// alts are the plans of alternative pipelines,
+		// the first of which to succeed sets the value.
+		alts []*valuePlan
+	}
+)
+
+// Compile compiles t, a struct or pointer to struct type, using the
+// default Scraper.
+func Compile(t reflect.Type) (*Plan, []error) {
+	return defaultScraper.Compile(t)
+}
+
+// MustCompile compiles T using the default Scraper and panics
+// if any tag in T is invalid.
+func MustCompile[T any]() *Plan {
+	p, errs := Compile(reflect.TypeOf((*T)(nil)).Elem())
+	if len(errs) > 0 {
+		panic(fmt.Sprintf("sq: MustCompile(%T): %q", *new(T), errs))
+	}
+	return p
+}
+
+// Compile compiles t, a struct or pointer to struct type.  The plan
+// is cached and reused by subsequent calls to Scrape until another
+// func or type loader is registered.  All tag errors found in t are
+// returned.
+func (s *Scraper) Compile(t reflect.Type) (*Plan, []error) {
+	p, err := s.plan(t)
+	if err != nil {
+		return nil, []error{err}
+	}
+	return p, p.errs
+}
+
+// Validate compiles the type of v, a struct, pointer to struct or
+// reflect.Type, using the default Scraper and returns a *TagError
+// for every invalid tag found.
+func Validate(v interface{}) []error {
+	return defaultScraper.Validate(v)
+}
+
+// Validate compiles the type of v, a struct, pointer to struct or
+// reflect.Type, and returns a *TagError for every invalid tag found.
+func (s *Scraper) Validate(v interface{}) []error {
+	t, isType := v.(reflect.Type)
+	if !isType {
+		t = reflect.TypeOf(v)
+	}
+	_, errs := s.Compile(t)
+	return errs
+}
+
+// Type returns the struct type the plan was compiled from.
+func (p *Plan) Type() reflect.Type {
+	return p.typ
+}
+
+// plan returns the cached plan for t, compiling it if necessary.
+func (s *Scraper) plan(t reflect.Type) (*Plan, error) {
+
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, ErrNonStructPtrValue
+	}
+
+	s.mu.RLock()
+	p, exists := s.plans[t]
+	s.mu.RUnlock()
+	if exists {
+		return p, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, exists := s.plans[t]; exists {
+		return p, nil
+	}
+
+	structs := map[reflect.Type]*structPlan{}
+	p = &Plan{
+		typ:  t,
+		root: &valuePlan{typ: t, st: s.compileStruct(t, structs), eager: s.eagerPointers},
+	}
+	p.errs = p.root.errors("", map[*structPlan]bool{})
+	s.plans[t] = p
+
+	return p, nil
+
+}
+
+// resetPlans drops all cached plans.  Must be called with s.mu held.
+func (s *Scraper) resetPlans() {
+	s.plans = map[reflect.Type]*Plan{}
+}
+
+func (s *Scraper) compileStruct(t reflect.Type, structs map[reflect.Type]*structPlan) *structPlan {
+
+	if sp, exists := structs[t]; exists {
+		return sp
+	}
+
+	// registered before compiling fields so
+	// recursive types resolve to this plan.
+	sp := &structPlan{}
+	structs[t] = sp
+
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+		p, err := s.parseTag(ft.Tag)
+		if err == ErrTagNotFound {
+			continue
+		}
+		fp := fieldPlan{index: i, name: ft.Name}
+		if err != nil {
+			fp.err = err.(*TagError)
+		} else if r, _ := utf8.DecodeRuneInString(ft.Name); !unicode.IsUpper(r) {
+			fp.err = &TagError{Tag: p.tag, Stage: "field", Err: fmt.Errorf("%w: %q", ErrPrivateField, ft.Name)}
+		} else {
+			fp.val = s.compileValue(ft.Type, p, structs)
+		}
+		sp.fields = append(sp.fields, fp)
+	}
+
+	return sp
+
+}
+
+func (s *Scraper) compileValue(t reflect.Type, p *path, structs map[reflect.Type]*structPlan) *valuePlan {
+	var vp *valuePlan
+	if len(p.alts) > 0 {
+		vp = s.compileAlts(t, p, structs)
+	} else {
+		vp = s.compileKind(t, p, structs)
+	}
+	if err := checkDefault(vp); err != nil {
+		vp.err, vp.stage = err, "default"
+	}
+	if p.pseudo != nil && vp.err == nil && !vp.leaf() {
+		vp.err, vp.stage = fmt.Errorf("%w: %s requires a value loaded from text, not %v", ErrBadTag, p.selector, vp.typ), "selector"
+	}
+	return vp
+}
+
+// compileAlts compiles each alternative pipeline of p.  Options
+// apply to the value as a whole, so they are not copied to the
+// alternatives, except for those shaping how a value is loaded.
+func (s *Scraper) compileAlts(t reflect.Type, p *path, structs map[reflect.Type]*structPlan) *valuePlan {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	vp := &valuePlan{path: p, typ: t, eager: s.eagerPointers}
+	for _, ap := range append([]*path{p}, p.alts...) {
+		alt := &path{
+			tag:        ap.tag,
+			selector:   ap.selector,
+			query:      ap.query,
+			acc:        ap.acc,
+			accessor:   ap.accessor,
+			parsers:    ap.parsers,
+			loader:     ap.loader,
+			typeLoader: p.typeLoader,
+			splitter:   ap.splitter,
+			each:       ap.each,
+			key:        p.key,
+			value:      p.value,
+			collection: p.collection,
+			location:   p.location,
+		}
+		vp.alts = append(vp.alts, s.compileKind(t, alt, structs))
+	}
+	return vp
+}
+
+func (s *Scraper) compileKind(t reflect.Type, p *path, structs map[reflect.Type]*structPlan) *valuePlan {
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	vp := &valuePlan{path: p, typ: t, eager: s.eagerPointers}
+
+	if (p.key != nil || p.value != nil) && t.Kind() != reflect.Map {
+		vp.err, vp.stage = fmt.Errorf("%w: key() and value() only apply to map fields", ErrBadTag), "option"
+		return vp
+	}
+
+	isBytes := (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+
+	if p.collection != nil && (t.Kind() != reflect.Slice || isBytes) {
+		vp.err, vp.stage = fmt.Errorf("%w: limit(), offset(), where(), skipinvalid, unique() and group() only apply to slice fields", ErrBadTag), "option"
+		return vp
+	}
+
+	// split values load the elements or fields
+	if p.splitter != nil {
+		s.compileSplit(vp, structs)
+		return vp
+	}
+
+	// in() applies to each element of a collection
+	if p.location != nil && (isBytes || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array && t.Kind() != reflect.Map)) {
+		s.compileLocation(vp)
+		return vp
+	}
+
+	// explicit loaders apply to each element of a collection
+	if p.loader != nil && (isBytes || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array && t.Kind() != reflect.Map)) {
+		if _, builtin := s.argCheckers[p.loader.name]; builtin && textLoaders[p.loader.name] && !fromText(t) {
+			vp.err, vp.stage = fmt.Errorf("%w: %s() loads text, not %v", ErrBadTag, p.loader.name, t), p.loader.name
+			return vp
+		}
+		vp.loader = p.loader
+		return vp
+	}
+
+	// as do type loaders chosen by the tag
+	if tl := p.typeLoader; tl != nil && (isBytes || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array)) {
+		if !tl.isType(t) {
+			vp.err, vp.stage = fmt.Errorf("%w: type loader %q does not load %v", ErrBadTag, tl.name, t), "loader"
+			return vp
+		}
+		vp.loader = tl.loader()
+		return vp
+	}
+
+	if tl, exists := s.findTypeLoader(t); exists {
+		vp.loader = tl.loader()
+		return vp
+	}
+
+	if l := unmarshalLoader(t); l != nil {
+		vp.loader = l
+		return vp
+	}
+
+	// maps and structs may be decoded from the
+	// attributes of their node
+	if attrs := attrMap(t, p); attrs != nil {
+		s.compileAttrs(vp, attrs, structs)
+		return vp
+	}
+
+	switch t.Kind() {
+
+	case reflect.Struct:
+		vp.st = s.compileStruct(t, structs)
+
+	case reflect.Array, reflect.Slice:
+		if !isBytes {
+			// elements are already selected, so
+			// they only run the rest of the pipeline.
+			ep := p
+			if p.collection != nil {
+				c := *p
+				c.collection = nil
+				ep = &c
+			}
+			vp.elem = s.compileValue(t.Elem(), ep, structs)
+			vp.elem.preselected = true
+			if c := p.collection; c != nil && c.unique != nil {
+				if vp.unique, vp.err = uniqueKey(t.Elem(), *c.unique); vp.err != nil {
+					vp.stage = "unique"
+				}
+			}
+		}
+
+	case reflect.Map:
+		if p.key == nil || p.value == nil {
+			vp.err, vp.stage = fmt.Errorf("%w: map fields require key() and value()", ErrBadTag), "option"
+			break
+		}
+		vp.key = s.compileValue(t.Key(), p.key, structs)
+		vp.elem = s.compileValue(t.Elem(), p.value, structs)
+
+	case reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64,
+		reflect.Uint,
+		reflect.Uint8,
+		reflect.Uint16,
+		reflect.Uint32,
+		reflect.Uint64,
+		reflect.Uintptr,
+		reflect.Float32,
+		reflect.Float64:
+		vp.locale = s.locale
+
+	case reflect.Bool,
+		reflect.Interface,
+		reflect.String:
+
+	default:
+		// case reflect.Complex64:
+		// case reflect.Complex128:
+		// case reflect.Chan:
+		// case reflect.Func:
+		vp.err, vp.stage = fmt.Errorf("%w: %v", ErrInvalidKind, t.Kind()), "kind"
+	}
+
+	return vp
+
+}
+
+// fromText reports whether values of type t are converted from
+// text without a loader.
+func fromText(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64,
+		reflect.Uint,
+		reflect.Uint8,
+		reflect.Uint16,
+		reflect.Uint32,
+		reflect.Uint64,
+		reflect.Uintptr,
+		reflect.Float32,
+		reflect.Float64,
+		reflect.String,
+		reflect.Interface:
+		return true
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// uniqueKey returns the func keying elements of type t by their
+// field called name, or by their value if name is "".  Pointers
+// are keyed by what they point to, and nil ones not at all.
+func uniqueKey(t reflect.Type, name string) (func(v reflect.Value) (interface{}, bool), error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var index []int
+	if name != "" {
+		if t.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("%w: unique(%s) requires struct elements, not %v", ErrBadTag, name, t)
+		}
+		sf, exists := t.FieldByName(name)
+		if !exists || !sf.IsExported() {
+			return nil, fmt.Errorf("%w: unique(%s): %v has no field %q", ErrBadTag, name, t, name)
+		}
+		index, t = sf.Index, sf.Type
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	if !t.Comparable() {
+		return nil, fmt.Errorf("%w: unique(%s): %v is not comparable", ErrBadTag, name, t)
+	}
+	deref := func(v reflect.Value) (reflect.Value, bool) {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		return v, true
+	}
+	return func(v reflect.Value) (interface{}, bool) {
+		v, ok := deref(v)
+		if !ok {
+			return nil, false
+		}
+		if index != nil {
+			fv, err := v.FieldByIndexErr(index)
+			if err != nil {
+				// a nil embedded pointer
+				return nil, false
+			}
+			if v, ok = deref(fv); !ok {
+				return nil, false
+			}
+		}
+		return v.Interface(), true
+	}, nil
+}
+
+// attrMap returns the attribute map a value of type t is decoded
+// from, or nil.  Maps of strings without key() and value() are
+// decoded from all attributes unless another accessor is given,
+// structs only when their accessor is attrs or dataset.
+func attrMap(t reflect.Type, p *path) func(*goquery.Selection) []html.Attribute {
+	if p.key != nil || p.value != nil {
+		return nil
+	}
+	switch {
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		if p.accessor == nil {
+			return attrs
+		}
+		return p.accessor.attrs
+	case t.Kind() == reflect.Struct && p.accessor != nil:
+		return p.accessor.attrs
+	}
+	return nil
+}
+
+// compileLocation loads vp with the time loader reading times
+// without a zone in the location set by in().
+func (s *Scraper) compileLocation(vp *valuePlan) {
+	p := vp.path
+	_, builtin := s.argCheckers["time"]
+	switch {
+	case p.loader != nil && (p.loader.name != "time" || !builtin):
+		vp.err, vp.stage = fmt.Errorf("%w: in() conflicts with %s()", ErrBadTag, p.loader.name), "in"
+	case p.loader == nil && (vp.typ != timeType || (p.typeLoader != nil && p.typeLoader.name != "time")):
+		vp.err, vp.stage = fmt.Errorf("%w: in() only applies to time() loaders and time.Time fields", ErrBadTag), "in"
+	default:
+		l := &loader{name: "time", f: (&timeParser{location: p.location, locale: s.locale}).load}
+		if p.loader != nil {
+			l.args = p.loader.args
+		}
+		vp.loader = l
+	}
+}
+
+// compileAttrs compiles vp, a map or struct decoded from an
+// attribute map.  Map values and struct fields are set from the
+// attribute values by the rest of the pipeline.
+func (s *Scraper) compileAttrs(vp *valuePlan, attrs func(*goquery.Selection) []html.Attribute, structs map[reflect.Type]*structPlan) {
+	p := vp.path
+	vp.attrs = attrs
+	s.compileEntries(vp, &path{
+		tag:        p.tag,
+		selector:   p.selector,
+		query:      p.query,
+		parsers:    p.parsers,
+		loader:     p.loader,
+		typeLoader: p.typeLoader,
+		location:   p.location,
+	}, structs)
+}
+
+// compileSplit compiles a value set from the values the splitter
+// of its pipeline returns: the elements of a slice or array, or by
+// name the fields of a struct or the entries of a map.
+func (s *Scraper) compileSplit(vp *valuePlan, structs map[reflect.Type]*structPlan) {
+	p := vp.path
+	sub := &path{
+		tag:        p.tag,
+		selector:   p.selector,
+		query:      p.query,
+		parsers:    p.each,
+		loader:     p.loader,
+		typeLoader: p.typeLoader,
+		location:   p.location,
+	}
+	t := vp.typ
+	switch {
+	case p.key != nil || p.value != nil || p.collection != nil:
+		vp.err, vp.stage = fmt.Errorf("%w: %s() conflicts with key(), value() and slice options", ErrBadTag, p.splitter.name), "option"
+	case p.splitter.named:
+		if (t.Kind() != reflect.Map || t.Key().Kind() != reflect.String) && t.Kind() != reflect.Struct {
+			vp.err, vp.stage = fmt.Errorf("%w: %s() requires a struct or map field, not %v", ErrBadTag, p.splitter.name, t), p.splitter.name
+			break
+		}
+		vp.split = p.splitter
+		s.compileEntries(vp, sub, structs)
+	default:
+		if (t.Kind() != reflect.Slice && t.Kind() != reflect.Array) || t.Elem().Kind() == reflect.Uint8 {
+			vp.err, vp.stage = fmt.Errorf("%w: %s() requires a slice or array field, not %v", ErrBadTag, p.splitter.name, t), p.splitter.name
+			break
+		}
+		vp.split = p.splitter
+		vp.elem = s.compileAttr(t.Elem(), sub, structs)
+	}
+}
+
+// compileEntries compiles the values of a map or the fields of a
+// struct set from named values, ie. attributes, with sub.
+func (s *Scraper) compileEntries(vp *valuePlan, sub *path, structs map[reflect.Type]*structPlan) {
+	if vp.typ.Kind() == reflect.Map {
+		vp.elem = s.compileAttr(vp.typ.Elem(), sub, structs)
+		return
+	}
+	vp.st = &structPlan{}
+	for i := 0; i < vp.typ.NumField(); i++ {
+		ft := vp.typ.Field(i)
+		if ft.PkgPath != "" {
+			continue
+		}
+		vp.st.fields = append(vp.st.fields, fieldPlan{index: i, name: ft.Name, val: s.compileAttr(ft.Type, sub, structs)})
+	}
+}
+
+func (s *Scraper) compileAttr(t reflect.Type, p *path, structs map[reflect.Type]*structPlan) *valuePlan {
+	vp := s.compileValue(t, p, structs)
+	if vp.err == nil && !vp.leaf() {
+		return &valuePlan{path: p, typ: vp.typ, err: fmt.Errorf("%w: attributes must be loaded from text, not %v", ErrBadTag, vp.typ), stage: "kind"}
+	}
+	return vp
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// unmarshalLoader returns a loader for types that implement
+// Unmarshaler or encoding.TextUnmarshaler, or nil.
+func unmarshalLoader(t reflect.Type) *loader {
+	switch pt := reflect.PtrTo(t); {
+	case pt.Implements(unmarshalerType):
+		return &loader{
+			name: "UnmarshalSQ",
+			f: func(sel *goquery.Selection, text, _ string) (interface{}, error) {
+				v := reflect.New(t)
+				return v.Interface(), v.Interface().(Unmarshaler).UnmarshalSQ(sel, text)
+			},
+		}
+	case pt.Implements(textUnmarshalerType):
+		return &loader{
+			name: "UnmarshalText",
+			f: func(_ *goquery.Selection, text, _ string) (interface{}, error) {
+				v := reflect.New(t)
+				return v.Interface(), v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+			},
+		}
+	}
+	return nil
+}
+
+// checkDefault verifies that the default of vp, if any, can be
+// set on a value of its type without a loader.
+func checkDefault(vp *valuePlan) error {
+	def := vp.path.def
+	if def == nil || vp.err != nil {
+		return nil
+	}
+	// defaults are loaded by the first alternative
+	if vp.alts != nil {
+		vp = vp.alts[0]
+		if vp.err != nil {
+			return nil
+		}
+	}
+	if !vp.leaf() {
+		return fmt.Errorf("%w: default() requires a value loaded from text, not %v", ErrBadTag, vp.typ)
+	}
+	// loaders may be user funcs expecting a document, so
+	// their defaults are only loaded, or fail, when scraping
+	if vp.loader != nil {
+		return nil
+	}
+	v := reflect.New(vp.typ).Elem()
+	if err := setValueFromString(&v, &goquery.Selection{}, vp, "", *def); err != nil {
+		return fmt.Errorf("%w: default(%s): %v", ErrBadTag, *def, err.Err)
+	}
+	return nil
+}
+
+// leaf reports whether vp is set directly from text.
+func (vp *valuePlan) leaf() bool {
+	return vp.st == nil && vp.elem == nil && vp.key == nil && vp.alts == nil
+}
+
+// findTypeLoader returns the first type loader, in resolution
+// order, that loads t.
+func (s *Scraper) findTypeLoader(t reflect.Type) (TypeLoader, bool) {
+	for _, tl := range s.typeLoaders {
+		if tl.isType(t) {
+			return tl, true
+		}
+	}
+	return TypeLoader{}, false
+}
+
+// errors collects every compile error reachable from vp, which
+// is found at field.
+func (vp *valuePlan) errors(field string, seen map[*structPlan]bool) []error {
+	if vp.err != nil {
+		return []error{vp.tagError(field)}
+	}
+	if vp.alts != nil {
+		var errs []error
+		for _, alt := range vp.alts {
+			errs = append(errs, alt.errors(field, seen)...)
+		}
+		return errs
+	}
+	if vp.key != nil {
+		return append(vp.key.errors(field+"[key]", seen), vp.elem.errors(field+"[]", seen)...)
+	}
+	if vp.elem != nil {
+		return vp.elem.errors(field+"[]", seen)
+	}
+	// seen guards against recursive types
+	if vp.st == nil || seen[vp.st] {
+		return nil
+	}
+	seen[vp.st] = true
+	defer delete(seen, vp.st)
+	var errs []error
+	for _, fp := range vp.st.fields {
+		name := fp.name
+		if field != "" {
+			name = field + "." + fp.name
+		}
+		if fp.err != nil {
+			err := *fp.err
+			err.Field = name
+			errs = append(errs, &err)
+		} else {
+			errs = append(errs, fp.val.errors(name, seen)...)
+		}
+	}
+	return errs
+}
+
+func (vp *valuePlan) tagError(field string) *TagError {
+	return &TagError{Field: field, Tag: vp.path.tag, Stage: vp.stage, Err: vp.err}
+}
@@ -0,0 +1,126 @@
+package sq
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type planNode struct {
+	Name     string      `sq:"span | text"`
+	Children []*planNode `sq:"ul > li"`
+}
+
+func TestCompile(t *testing.T) {
+
+	s := New()
+
+	p1, errs := s.Compile(reflect.TypeOf(&planNode{}))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	p2, _ := s.Compile(reflect.TypeOf(planNode{}))
+	if p1 != p2 {
+		t.Errorf("Expected cached plan to be reused")
+	}
+	if p1.Type() != reflect.TypeOf(planNode{}) {
+		t.Errorf("Expected %v, got %v", reflect.TypeOf(planNode{}), p1.Type())
+	}
+
+	// registering invalidates cached plans
+	s.RegisterParseFunc("noop", func(s, _ string) (string, error) { return s, nil })
+	if p3, _ := s.Compile(reflect.TypeOf(planNode{})); p3 == p1 {
+		t.Errorf("Expected plan to be recompiled after registration")
+	}
+
+	if _, errs := s.Compile(reflect.TypeOf("")); len(errs) != 1 || errs[0] != ErrNonStructPtrValue {
+		t.Errorf("Expected %q, got %q", ErrNonStructPtrValue, errs)
+	}
+
+}
+
+func TestCompileErrors(t *testing.T) {
+
+	type bad struct {
+		Regexp string            `sq:"p | text | regexp('(')"`
+		Sel    string            `sq:"p >"`
+		Func   string            `sq:"p | text | nofunc"`
+		Chan   chan int          `sq:"p"`
+		Map    map[string]string `sq:"p | text"`
+		Def    int               `sq:"p | text, default(x)"`
+		Both   string            `sq:"p, optional, required"`
+		Nested []struct {
+			Acc string `sq:"p | txt"`
+		} `sq:"div"`
+	}
+
+	expected := []string{
+		"Regexp: regexp('('): invalid regexp: error parsing regexp: missing closing ): `'('`",
+		`Sel: invalid selector: "p >": expected selector, found EOF instead`,
+		`Func: "nofunc" not registered func`,
+		`Chan: invalid kind: chan`,
+		`Map: Bad tag: map fields require key() and value()`,
+		`Def: Bad tag: default(x): strconv.ParseInt: parsing "x": invalid syntax`,
+		`Both: Bad tag: required conflicts with optional and default()`,
+		`Nested[].Acc: Bad accessor: "txt"`,
+	}
+
+	_, errs := New().Compile(reflect.TypeOf(bad{}))
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %q, got %q", expected, errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], err)
+		}
+	}
+
+	// the same problems reported by Validate with their details
+	expectedTagErrs := []struct {
+		field, tag, stage string
+		reason            error
+	}{
+		{"Regexp", "p | text | regexp('(')", "regexp", ErrInvalidRegexp},
+		{"Sel", "p >", "selector", ErrInvalidSelector},
+		{"Func", "p | text | nofunc", "nofunc", ErrUnknownFunc},
+		{"Chan", "p", "kind", ErrInvalidKind},
+		{"Map", "p | text", "option", ErrBadTag},
+		{"Def", "p | text, default(x)", "default", ErrBadTag},
+		{"Both", "p, optional, required", "required", ErrBadTag},
+		{"Nested[].Acc", "p | txt", "accessor", ErrBadAccessor},
+	}
+	errs = New().Validate(&bad{})
+	if len(errs) != len(expectedTagErrs) {
+		t.Fatalf("Expected %d errors, got %q", len(expectedTagErrs), errs)
+	}
+	for i, err := range errs {
+		exp := expectedTagErrs[i]
+		var te *TagError
+		if !errors.As(err, &te) {
+			t.Errorf("Expected *TagError, got %T", err)
+			continue
+		}
+		if te.Field != exp.field || te.Tag != exp.tag || te.Stage != exp.stage {
+			t.Errorf("Expected %q %q %q, got %q %q %q", exp.field, exp.tag, exp.stage, te.Field, te.Tag, te.Stage)
+		}
+		if !errors.Is(err, exp.reason) {
+			t.Errorf("Expected %q to wrap %q", err, exp.reason)
+		}
+	}
+
+	type private struct {
+		field string `sq:"p"`
+	}
+	errs = Validate(reflect.TypeOf(private{}))
+	if len(errs) != 1 || !errors.Is(errs[0], ErrPrivateField) {
+		t.Errorf("Expected %q, got %q", ErrPrivateField, errs)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected MustCompile to panic")
+		}
+	}()
+	MustCompile[bad]()
+
+}
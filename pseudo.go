@@ -0,0 +1,176 @@
+package sq
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// pseudoClass describes a text matching pseudo-class sq adds to
+// those of cascadia.  They are rewritten into cascadia's :matches()
+// and :matchesOwn() before a selector is compiled.
+type pseudoClass struct {
+	// own matches the node's own text only.
+	own bool
+	// fold matches case-insensitively.
+	fold bool
+	// regexp takes a regexp rather than a literal.
+	regexp bool
+	// words matches the words of the arg separated
+	// by any whitespace.
+	words bool
+}
+
+// pseudoClasses are keyed by lower case name.  Names starting with
+// "i" are the case-insensitive variants.  :contains() and
+// :containsOwn() fold case, as they do in cascadia, so their
+// case-sensitive variants end with "case".
+var pseudoClasses = map[string]pseudoClass{
+	"contains":        {fold: true},
+	"icontains":       {fold: true},
+	"containscase":    {},
+	"containsown":     {own: true, fold: true},
+	"icontainsown":    {own: true, fold: true},
+	"containsowncase": {own: true},
+	"imatches":        {fold: true, regexp: true},
+	"imatchesown":     {own: true, fold: true, regexp: true},
+	"has-text":        {words: true},
+	"ihas-text":       {words: true, fold: true},
+}
+
+// rewritePseudoClasses rewrites the text pseudo-classes in selector
+// into :matches() or :matchesOwn() with an equivalent regexp.
+func rewritePseudoClasses(selector string) (string, error) {
+
+	var b strings.Builder
+
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		switch {
+		case c == '\\' && i+1 < len(selector):
+			b.WriteString(selector[i : i+2])
+			i++
+			continue
+		case c == '"' || c == '\'':
+			j, err := skipQuoted(selector, i)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(selector[i : j+1])
+			i = j
+			continue
+		case c != ':':
+			b.WriteByte(c)
+			continue
+		}
+
+		j := i + 1
+		for j < len(selector) && isPseudoRune(selector[j]) {
+			j++
+		}
+		name := strings.ToLower(selector[i+1 : j])
+		pc, exists := pseudoClasses[name]
+		if !exists || j == len(selector) || selector[j] != '(' {
+			b.WriteByte(c)
+			continue
+		}
+
+		arg, end, err := pseudoArg(selector, j, pc.regexp)
+		if err != nil {
+			return "", err
+		}
+		pattern, err := pc.pattern(arg)
+		if err != nil {
+			return "", fmt.Errorf(":%s(%s): %w", name, arg, err)
+		}
+		if pc.own {
+			b.WriteString(":matchesOwn(" + pattern + ")")
+		} else {
+			b.WriteString(":matches(" + pattern + ")")
+		}
+		i = end
+	}
+
+	return b.String(), nil
+
+}
+
+// pseudoArg returns the arg of the pseudo-class whose parenthesis
+// opens at i, and the index of its closing parenthesis.  Regexps
+// end at the first unbalanced parenthesis or bracket, as they do
+// in cascadia, other args may be quoted.
+func pseudoArg(s string, i int, isRegexp bool) (string, int, error) {
+	if isRegexp {
+		open := 0
+		for j := i + 1; j < len(s); j++ {
+			switch s[j] {
+			case '(', '[':
+				open++
+			case ')', ']':
+				if open--; open < 0 {
+					return s[i+1 : j], j, nil
+				}
+			}
+		}
+		return "", 0, syntaxError(i+1, "unclosed %q", '(')
+	}
+	j := i + 1
+	for j < len(s) && s[j] == ' ' {
+		j++
+	}
+	if j < len(s) && (s[j] == '"' || s[j] == '\'') {
+		k, err := skipQuoted(s, j)
+		if err != nil {
+			return "", 0, err
+		}
+		arg := unquote(s[j : k+1])
+		for k++; k < len(s) && s[k] == ' '; k++ {
+		}
+		if k == len(s) || s[k] != ')' {
+			return "", 0, syntaxError(k+1, "expected ')' after quoted arg")
+		}
+		return arg, k, nil
+	}
+	k := strings.IndexByte(s[j:], ')')
+	if k == -1 {
+		return "", 0, syntaxError(i+1, "unclosed %q", '(')
+	}
+	return strings.TrimSpace(s[j : j+k]), j + k, nil
+}
+
+// pattern returns the regexp matching arg.
+func (pc pseudoClass) pattern(arg string) (string, error) {
+	var pattern string
+	switch {
+	case pc.regexp:
+		pattern = arg
+	case pc.words:
+		words := strings.Fields(arg)
+		for i, w := range words {
+			words[i] = literal(w)
+		}
+		pattern = strings.Join(words, `\s+`)
+	default:
+		pattern = literal(arg)
+	}
+	if pc.fold {
+		pattern = "(?i)" + pattern
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidRegexp, err)
+	}
+	return pattern, nil
+}
+
+// literal quotes s for use in a regexp.  Parentheses and brackets
+// are written as hex escapes since cascadia balances them to find
+// the end of the regexp.
+func literal(s string) string {
+	return parens.Replace(regexp.QuoteMeta(s))
+}
+
+var parens = strings.NewReplacer(`\(`, `\x28`, `\)`, `\x29`, `\[`, `\x5b`, `\]`, `\x5d`)
+
+func isPseudoRune(c byte) bool {
+	return c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
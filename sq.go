@@ -6,10 +6,12 @@ import (
 	"io"
 	"reflect"
 	"strconv"
-	"unicode"
-	"unicode/utf8"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
 )
 
 var (
@@ -19,16 +21,186 @@ var (
 	ErrNonStructPtrValue = errors.New("*struct type required")
 	ErrTagNotFound       = errors.New("sq tag not found")
 
+	// tag errors
+	ErrBadTag            = errors.New("Bad tag")
+	ErrBadAccessor       = errors.New("Bad accessor")
+	ErrUnknownFunc       = errors.New("not registered func")
+	ErrPrivateField      = errors.New("private field with sq tag")
+	ErrUnknownTypeLoader = errors.New("not registered type loader")
+	ErrInvalidXPath      = errors.New("invalid xpath")
+	ErrInvalidSelector   = errors.New("invalid selector")
+
+	// ErrRequired marks a missing node or attribute of a
+	// field tagged required.
+	ErrRequired = errors.New("required")
+
 	// not found errors
 	ErrNodeNotFound      = errors.New("node not found")
 	ErrAttributeNotFound = errors.New("attribute not found")
 )
 
+type (
+	// Scraper hydrates structs from html using its own set of
+	// accessors, parse funcs, load funcs and type loaders.
+	// Registering on one Scraper does not affect any other.  A
+	// Scraper is safe for concurrent use.
+	Scraper struct {
+		mu         sync.RWMutex
+		parseFuncs map[string]ParseFunc
+		loadFuncs  map[string]LoadFunc
+		accessors  map[string]accessorDef
+		// typeLoaders are kept in resolution order.
+		typeLoaders []TypeLoader
+		argCheckers map[string]func(args string) error
+		plans       map[reflect.Type]*Plan
+		// eagerPointers allocates pointer fields
+		// before their selector is matched.
+		eagerPointers bool
+		// locale reads number fields, which are
+		// parsed by strconv if it is nil.
+		locale *locale
+		// location holds times read without a zone,
+		// UTC if it is nil.
+		location *time.Location
+	}
+
+	// Option configures a Scraper created with New.
+	Option func(*Scraper)
+)
+
+// defaultScraper backs the package level functions.
+var defaultScraper = New()
+
+// New returns a Scraper initialized with copies of the built-in
+// accessors, parse funcs, load funcs and type loaders, then applies
+// opts.
+func New(opts ...Option) *Scraper {
+	s := &Scraper{
+		parseFuncs:  make(map[string]ParseFunc, len(parseFuncs)),
+		loadFuncs:   make(map[string]LoadFunc, len(loadFuncs)),
+		accessors:   make(map[string]accessorDef, len(accessors)),
+		typeLoaders: append([]TypeLoader(nil), typeLoaders...),
+		argCheckers: make(map[string]func(string) error, len(argCheckers)),
+		plans:       map[reflect.Type]*Plan{},
+	}
+	for name, f := range parseFuncs {
+		s.parseFuncs[name] = f
+	}
+	for name, f := range loadFuncs {
+		s.loadFuncs[name] = f
+	}
+	for name, def := range accessors {
+		s.accessors[name] = def
+	}
+	for name, f := range argCheckers {
+		s.argCheckers[name] = f
+	}
+	for i := range s.typeLoaders {
+		s.typeLoaders[i].builtin = true
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithParseFunc registers a parse func on the new Scraper.
+func WithParseFunc(name string, f ParseFunc) Option {
+	return func(s *Scraper) { s.setParseFunc(name, f) }
+}
+
+// WithLoadFunc registers a load func on the new Scraper.
+func WithLoadFunc(name string, f LoadFunc) Option {
+	return func(s *Scraper) { s.setLoadFunc(name, f) }
+}
+
+// WithAccessor registers an accessor on the new Scraper.
+func WithAccessor(name string, f AccessorFunc) Option {
+	return func(s *Scraper) { s.accessors[name] = accessorDef{f: f, arity: -1} }
+}
+
+// WithEagerPointers allocates every pointer field before its
+// selector is matched, as earlier versions of sq did, instead of
+// leaving it nil until a value is set.
+func WithEagerPointers() Option {
+	return func(s *Scraper) { s.eagerPointers = true }
+}
+
+// WithLocale reads the numbers of int, uint and float fields, of
+// number() loaders without a locale and of Money fields as written
+// in the locale called name, ie. "de" or "fr-CH".  The region of
+// the locale settles ambiguous currency symbols, so $ is USD in
+// en-US, and month and day names in times are read in its
+// language.  It panics if the locale is unknown.
+func WithLocale(name string) Option {
+	return func(s *Scraper) {
+		loc, exists := findLocale(name)
+		if !exists {
+			panic(fmt.Sprintf("sq: WithLocale(%q): %v", name, ErrUnknownLocale))
+		}
+		s.locale = loc
+		// an overridden number() has no checker
+		if _, builtin := s.argCheckers["number"]; builtin {
+			s.loadFuncs["number"] = numberLoader(loc)
+		}
+		if i := s.typeLoaderIndex("money"); i > -1 && s.typeLoaders[i].builtin {
+			s.typeLoaders[i].load = moneyLoader(loc)
+		}
+		s.setTimeLoaders()
+	}
+}
+
+// WithLocation reads times without a zone, in time() loaders
+// without in() and time.Time fields, in loc rather than UTC.
+func WithLocation(loc *time.Location) Option {
+	return func(s *Scraper) {
+		s.location = loc
+		s.setTimeLoaders()
+	}
+}
+
+// setTimeLoaders has the built-in time loaders read times in the
+// location of s and names in the language of its locale.
+func (s *Scraper) setTimeLoaders() {
+	tp := &timeParser{location: s.location, locale: s.locale}
+	// an overridden time() has no checker
+	if _, builtin := s.argCheckers["time"]; builtin {
+		s.loadFuncs["time"] = tp.load
+	}
+	if i := s.typeLoaderIndex("time"); i > -1 && s.typeLoaders[i].builtin {
+		s.typeLoaders[i].load = timeLoader(tp)
+	}
+}
+
+// WithTypeLoader registers a type loader on the new Scraper.
+func WithTypeLoader(name string, isType func(t reflect.Type) bool, load func(sel *goquery.Selection, text string) (interface{}, error)) Option {
+	return WithTypeLoaderPriority(name, 0, isType, load)
+}
+
+// WithTypeLoaderPriority registers a type loader with the given
+// priority on the new Scraper.
+func WithTypeLoaderPriority(name string, priority int, isType func(t reflect.Type) bool, load func(sel *goquery.Selection, text string) (interface{}, error)) Option {
+	return func(s *Scraper) {
+		s.setTypeLoader(TypeLoader{name: name, priority: priority, isType: isType, load: load})
+	}
+}
+
+// Scrape parses the html from r and hydrates structPtr using the
+// default Scraper.
 func Scrape(structPtr interface{}, r io.Reader) []error {
+	return defaultScraper.Scrape(structPtr, r)
+}
 
-	v := reflect.ValueOf(structPtr)
+// ScrapeSelection hydrates structPtr from an already parsed
+// selection using the default Scraper.
+func ScrapeSelection(structPtr interface{}, sel *goquery.Selection) []error {
+	return defaultScraper.ScrapeSelection(structPtr, sel)
+}
 
-	if v.Kind() != reflect.Ptr || v.Type().Elem().Kind() != reflect.Struct {
+// Scrape parses the html from r and hydrates structPtr.
+func (s *Scraper) Scrape(structPtr interface{}, r io.Reader) []error {
+
+	if !isStructPtr(structPtr) {
 		return []error{ErrNonStructPtrValue}
 	}
 
@@ -37,8 +209,116 @@ func Scrape(structPtr interface{}, r io.Reader) []error {
 		return []error{err}
 	}
 
-	return hydrateValue(&v, doc.Selection, nil)
+	return s.ScrapeSelection(structPtr, doc.Selection)
+
+}
+
+// ScrapeSelection hydrates structPtr from an already parsed selection.
+func (s *Scraper) ScrapeSelection(structPtr interface{}, sel *goquery.Selection) []error {
+
+	if !isStructPtr(structPtr) {
+		return []error{ErrNonStructPtrValue}
+	}
+
+	return s.hydrate(reflect.ValueOf(structPtr).Elem(), sel, "", position{count: 1})
+
+}
+
+// Source is an already parsed document, or part of one.
+type Source interface {
+	*goquery.Document | *goquery.Selection | *html.Node
+}
+
+// ScrapeFrom hydrates structPtr from an already parsed document,
+// selection or node using the default Scraper.
+func ScrapeFrom[S Source](structPtr interface{}, src S) []error {
+	return ScrapeFromWith(defaultScraper, structPtr, src)
+}
 
+// ScrapeFromWith is ScrapeFrom using s.
+func ScrapeFromWith[S Source](s *Scraper, structPtr interface{}, src S) []error {
+	return s.ScrapeSelection(structPtr, selectionOf(src))
+}
+
+// ScrapeAs parses the html from r and returns a T hydrated by the
+// default Scraper.  T must be a struct or pointer to struct.  The
+// returned error joins every error that occurred; T is hydrated as
+// far as possible regardless.
+func ScrapeAs[T any](r io.Reader) (T, error) {
+	return ScrapeAsWith[T](defaultScraper, r)
+}
+
+// ScrapeAsWith is ScrapeAs using s.
+func ScrapeAsWith[T any](s *Scraper, r io.Reader) (T, error) {
+	var v T
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return v, err
+	}
+	errs := s.hydrate(reflect.ValueOf(&v).Elem(), doc.Selection, "", position{count: 1})
+	return v, errors.Join(errs...)
+}
+
+// ScrapeAll parses the html from r and returns a T hydrated by the
+// default Scraper for every node matching selector, which may be
+// anything a tag selects with.  The fields of T are selected
+// relative to their node.  T must be a struct or pointer to struct.
+// The returned error joins every error that occurred.
+func ScrapeAll[T any](r io.Reader, selector string) ([]T, error) {
+	return ScrapeAllWith[T](defaultScraper, r, selector)
+}
+
+// ScrapeAllWith is ScrapeAll using s.
+func ScrapeAllWith[T any](s *Scraper, r io.Reader, selector string) ([]T, error) {
+	q, err := compileQuery(strings.TrimSpace(selector))
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		sel  = q(doc.Selection)
+		vs   = make([]T, sel.Size())
+		errs []error
+	)
+	sel.Each(func(i int, sel *goquery.Selection) {
+		v := reflect.ValueOf(&vs[i]).Elem()
+		errs = append(errs, s.hydrate(v, sel, fmt.Sprintf("[%d]", i), position{i, len(vs)})...)
+	})
+	return vs, errors.Join(errs...)
+}
+
+func selectionOf[S Source](src S) *goquery.Selection {
+	switch src := any(src).(type) {
+	case *goquery.Document:
+		return src.Selection
+	case *goquery.Selection:
+		return src
+	case *html.Node:
+		return goquery.NewDocumentFromNode(src).Selection
+	}
+	panic("unreachable")
+}
+
+// hydrate sets v, a settable struct or pointer to struct, from sel.
+// field prefixes the paths of errors and pos is the position of sel
+// among the nodes hydrated with v.
+func (s *Scraper) hydrate(v reflect.Value, sel *goquery.Selection, field string, pos position) []error {
+
+	p, err := s.plan(v.Type())
+	if err != nil {
+		return []error{err}
+	}
+
+	return hydrateValue(&v, sel, p.root, field, pos)
+
+}
+
+func isStructPtr(structPtr interface{}) bool {
+	v := reflect.ValueOf(structPtr)
+	return v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct
 }
 
 // initialize and dereference pointers
@@ -51,86 +331,103 @@ func resolvePointer(v *reflect.Value) {
 	}
 }
 
-func hydrateValue(v *reflect.Value, sel *goquery.Selection, p *path) []error {
+// position is the index of a node among those matched by the
+// selector of its collection, and their count.  Values outside a
+// collection are at index 0 of 1.
+type position struct {
+	index, count int
+}
 
-	resolvePointer(v)
+// hydrateValue sets v from sel as described by vp.  field is the
+// path to v from the root struct and is used to report errors, pos
+// is the position of sel in its collection.
+func hydrateValue(v *reflect.Value, sel *goquery.Selection, vp *valuePlan, field string, pos position) []error {
+
+	// pointers are only allocated once a value is set,
+	// unless the Scraper was created WithEagerPointers.
+	if vp.eager {
+		resolvePointer(v)
+	}
 
 	if !v.CanSet() {
 		return nil
 	}
 
-	if p != nil && len(p.selector) > 0 && p.selector != "." && !sel.Is(p.selector) {
-		sel = sel.Find(p.selector)
-		if sel.Size() == 0 {
-			return []error{fmt.Errorf("%q did not match", p.selector)}
-		}
+	// bad tags fail before options such as
+	// default() apply to a missing node
+	if vp.err != nil {
+		return []error{vp.tagError(field)}
 	}
 
-	if p != nil && p.loader != nil {
-		if err := setValueFromSel(v, sel, p); err != nil {
-			return []error{err}
+	if vp.alts != nil {
+		return hydrateAlts(v, sel, vp, field, pos)
+	}
+
+	if p := vp.path; p != nil && !vp.preselected && p.pseudo == nil {
+		found := p.query(sel)
+		if found.Size() == 0 && !(vp.leaf() && p.accessor != nil && p.accessor.empty) {
+			// missing nodes are located by their parent
+			return absent(v, found, vp, newFieldError(field, vp, sel, "select", "", ErrNodeNotFound))
 		}
-		return nil
+		sel = found
 	}
 
-	t := v.Type()
+	if vp.attrs != nil {
+		return hydrateEntries(v, sel, vp, field, attrEntries(vp.attrs(sel)))
+	}
 
-	for _, tl := range typeLoaders {
-		if tl.isType(t) {
-			p.loader = &loader{
-				f: func(sel *goquery.Selection, text, _ string) (interface{}, error) {
-					return tl.load(sel, text)
-				},
-			}
-			if err := setValueFromSel(v, sel, p); err != nil {
-				return []error{err}
+	if vp.split != nil {
+		return hydrateSplit(v, sel, vp, field)
+	}
+
+	if vp.leaf() {
+		if err := setValueFromSel(v, sel, vp, field, pos); err != nil {
+			if errors.Is(err, ErrAttributeNotFound) {
+				return absent(v, sel, vp, err)
 			}
-			return nil
+			return []error{err}
 		}
+		return nil
 	}
 
+	resolvePointer(v)
+
 	switch v.Kind() {
 
 	case reflect.Struct:
 
 		var errs []error
-		for i := 0; i < t.NumField(); i++ {
-			ft := t.Field(i)
-			p, err := parseTag(ft.Tag)
-			if err != nil {
-				if err != ErrTagNotFound {
-					errs = append(errs, err)
-				}
-			} else {
-				if r, _ := utf8.DecodeRuneInString(ft.Name); !unicode.IsUpper(r) {
-					errs = append(errs, fmt.Errorf("private field with sq tag: %q", ft.Name))
-				} else {
-					f := v.Field(i)
-					if err := hydrateValue(&f, sel, p); err != nil {
-						errs = append(errs, err...)
-					}
+		for _, fp := range vp.st.fields {
+			name := fp.name
+			if field != "" {
+				name = field + "." + fp.name
+			}
+			if fp.err != nil {
+				err := *fp.err
+				err.Field = name
+				errs = append(errs, &err)
+				continue
+			}
+			f := v.Field(fp.index)
+			if err := hydrateValue(&f, sel, fp.val, name, pos); err != nil {
+				if fp.val.path != nil && fp.val.path.required {
+					markRequired(err)
 				}
+				errs = append(errs, err...)
 			}
 		}
 		return errs
 
 	case reflect.Array:
 
-		// handle [N]byte copy from string
-		if t.Elem().Kind() == reflect.Uint8 {
-			s, err := extractString(sel, p.acc)
-			if err != nil {
-				return []error{err}
-			}
-			reflect.Copy(*v, reflect.ValueOf([]byte(s)))
-			return nil
-		}
-
-		var errs []error
+		var (
+			errs []error
+			n    = sel.Size()
+		)
 		sel.Each(func(i int, sel *goquery.Selection) {
 			if i < v.Len() {
 				vv := v.Index(i)
-				if err := hydrateValue(&vv, sel, p); err != nil {
+				if err := hydrateValue(&vv, sel, vp.elem, fmt.Sprintf("%s[%d]", field, i), position{i, n}); err != nil {
 					errs = append(errs, err...)
 				}
 			}
@@ -139,77 +436,333 @@ func hydrateValue(v *reflect.Value, sel *goquery.Selection, p *path) []error {
 
 	case reflect.Slice:
 
-		// handle []byte setting directly
-		if t.Elem().Kind() == reflect.Uint8 {
-			s, err := extractString(sel, p.acc)
-			if err != nil {
-				return []error{err}
+		c := vp.path.collection
+		if c == nil {
+			c = &collection{}
+		}
+
+		var (
+			errs  []error
+			elems = c.elements(sel)
+			n     = len(elems)
+			seen  = map[interface{}]bool{}
+		)
+		slicev := reflect.MakeSlice(vp.typ, 0, n)
+		for i, sel := range elems {
+			if c.limit > 0 && slicev.Len() == c.limit {
+				break
 			}
-			v.SetBytes([]byte(s))
-			return nil
+			ev := reflect.New(vp.typ.Elem()).Elem()
+			vv := ev
+			err := hydrateValue(&vv, sel, vp.elem, fmt.Sprintf("%s[%d]", field, slicev.Len()), position{i, n})
+			if c.skipInvalid && hasRequiredErrors(err) {
+				// bad tags are reported even if
+				// their elements are dropped
+				errs = append(errs, tagErrors(err)...)
+				continue
+			}
+			errs = append(errs, err...)
+			if vp.unique != nil {
+				// elements without a key are all kept
+				if key, ok := vp.unique(ev); ok {
+					if seen[key] {
+						continue
+					}
+					seen[key] = true
+				}
+			}
+			slicev = reflect.Append(slicev, ev)
 		}
+		v.Set(slicev)
+		return errs
 
-		var errs []error
-		slicev := reflect.MakeSlice(t, sel.Size(), sel.Size())
+	case reflect.Map:
+
+		var (
+			errs []error
+			n    = sel.Size()
+		)
+		mapv := reflect.MakeMapWithSize(vp.typ, n)
 		sel.Each(func(i int, sel *goquery.Selection) {
-			vv := slicev.Index(i)
-			if err := hydrateValue(&vv, sel, p); err != nil {
+			kv := reflect.New(vp.typ.Key()).Elem()
+			if err := hydrateValue(&kv, sel, vp.key, fmt.Sprintf("%s[%d]", field, i), position{i, n}); err != nil {
 				errs = append(errs, err...)
+				return
 			}
+			vv := reflect.New(vp.typ.Elem()).Elem()
+			if err := hydrateValue(&vv, sel, vp.elem, fmt.Sprintf("%s[%d]", field, i), position{i, n}); err != nil {
+				errs = append(errs, err...)
+				return
+			}
+			mapv.SetMapIndex(kv, vv)
 		})
-		v.Set(slicev)
+		v.Set(mapv)
 		return errs
 
-	case reflect.Bool,
-		reflect.Int,
-		reflect.Int8,
-		reflect.Int16,
-		reflect.Int32,
-		reflect.Int64,
-		reflect.Uint,
-		reflect.Uint8,
-		reflect.Uint16,
-		reflect.Uint32,
-		reflect.Uint64,
-		reflect.Uintptr,
-		reflect.Float32,
-		reflect.Float64,
-		reflect.Interface,
-		reflect.String:
-		if err := setValueFromSel(v, sel, p); err != nil {
+	}
+
+	panic("unreachable")
+
+}
+
+// attrEntries returns attrs as named values.
+func attrEntries(attrs []html.Attribute) []entry {
+	entries := make([]entry, len(attrs))
+	for i, a := range attrs {
+		entries[i] = entry{name: a.Key, value: a.Val}
+	}
+	return entries
+}
+
+// hydrateEntries sets v, a map or struct, from named values such
+// as the attribute map of sel.  Struct fields are set from the value
+// of the same name, ignoring case, and left untouched if there is
+// none.
+func hydrateEntries(v *reflect.Value, sel *goquery.Selection, vp *valuePlan, field string, entries []entry) []error {
+
+	resolvePointer(v)
+
+	var errs []error
+
+	if vp.st == nil {
+		if vp.elem.err != nil {
+			return []error{vp.elem.tagError(field + "[]")}
+		}
+		mapv := reflect.MakeMapWithSize(vp.typ, len(entries))
+		for _, e := range entries {
+			vv := reflect.New(vp.typ.Elem()).Elem()
+			if err := setValueFromText(&vv, sel, vp.elem, fmt.Sprintf("%s[%s]", field, e.name), e.value); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			mapv.SetMapIndex(reflect.ValueOf(e.name).Convert(vp.typ.Key()), vv)
+		}
+		v.Set(mapv)
+		return errs
+	}
+
+	for _, fp := range vp.st.fields {
+		name := fp.name
+		if field != "" {
+			name = field + "." + fp.name
+		}
+		for _, e := range entries {
+			if !strings.EqualFold(e.name, fp.name) {
+				continue
+			}
+			if fp.err != nil {
+				err := *fp.err
+				err.Field = name
+				errs = append(errs, &err)
+				break
+			}
+			if fp.val.err != nil {
+				errs = append(errs, fp.val.tagError(name))
+				break
+			}
+			fv := v.Field(fp.index)
+			if err := setValueFromText(&fv, sel, fp.val, name, e.value); err != nil {
+				errs = append(errs, err)
+			}
+			break
+		}
+	}
+	return errs
+
+}
+
+// hydrateSplit sets v from the values the splitter of vp returns
+// for the text of sel.  Arrays take as many values as they hold.
+func hydrateSplit(v *reflect.Value, sel *goquery.Selection, vp *valuePlan, field string) []error {
+
+	s, err := vp.path.accessor.extract(sel)
+	if err != nil {
+		return []error{newFieldError(field, vp, sel, "accessor", "", err)}
+	}
+	s, ferr := parseText(sel, vp, field, s)
+	if ferr != nil {
+		return []error{ferr}
+	}
+	values, err := vp.split.split(s)
+	if err != nil {
+		return []error{newFieldError(field, vp, sel, vp.split.name, s, err)}
+	}
+
+	if vp.st != nil || vp.typ.Kind() == reflect.Map {
+		return hydrateEntries(v, sel, vp, field, values)
+	}
+
+	if vp.elem.err != nil {
+		return []error{vp.elem.tagError(field + "[]")}
+	}
+
+	var (
+		errs []error
+		n    = len(values)
+		tv   reflect.Value
+	)
+	if vp.typ.Kind() == reflect.Slice {
+		tv = reflect.MakeSlice(vp.typ, n, n)
+	} else {
+		tv = reflect.New(vp.typ).Elem()
+		n = min(n, tv.Len())
+	}
+	for i := 0; i < n; i++ {
+		ev := tv.Index(i)
+		if err := setValueFromText(&ev, sel, vp.elem, fmt.Sprintf("%s[%d]", field, i), values[i].value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	resolvePointer(v)
+	v.Set(tv)
+	return errs
+
+}
+
+// hydrateAlts sets v from the first alternative of vp that yields a
+// value without error.  If every alternative fails, the errors of
+// all attempts are returned, or the options of vp are applied if
+// none of them found its node or attribute.
+func hydrateAlts(v *reflect.Value, sel *goquery.Selection, vp *valuePlan, field string, pos position) []error {
+
+	var (
+		errs    []error
+		missing []*FieldError
+	)
+	for _, alt := range vp.alts {
+		// hydrateValue dereferences hv, tv keeps any pointer
+		tv := reflect.New(v.Type()).Elem()
+		hv := tv
+		altErrs := hydrateValue(&hv, sel, alt, field, pos)
+		if len(altErrs) == 0 {
+			v.Set(tv)
+			return nil
+		}
+		for _, err := range altErrs {
+			var fe *FieldError
+			if errors.As(err, &fe) && (errors.Is(fe, ErrNodeNotFound) || errors.Is(fe, ErrAttributeNotFound)) {
+				missing = append(missing, fe)
+			}
+		}
+		errs = append(errs, altErrs...)
+	}
+
+	p := vp.path
+	switch {
+	case len(missing) < len(errs):
+	case p.optional || p.def != nil:
+		return absent(v, sel, vp, missing[0])
+	case p.required:
+		for _, err := range missing {
+			err.Err = fmt.Errorf("%w: %w", ErrRequired, err.Err)
+		}
+	}
+	return errs
+
+}
+
+// absent handles a value whose node or attribute is not present.
+// Optional values are zeroed and values with a default are set
+// from it.  Otherwise err is returned, marked if the value was
+// required.
+func absent(v *reflect.Value, sel *goquery.Selection, vp *valuePlan, err *FieldError) []error {
+	p := vp.path
+	// defaults are loaded by the first alternative
+	if vp.alts != nil {
+		vp = vp.alts[0]
+	}
+	switch {
+	case vp.err != nil:
+		return []error{vp.tagError(err.Path)}
+	case p.def != nil:
+		if err := setValueFromString(v, sel, vp, err.Path, *p.def); err != nil {
 			return []error{err}
 		}
 		return nil
+	case p.optional:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	case p.required:
+		err.Err = fmt.Errorf("%w: %w", ErrRequired, err.Err)
+	}
+	return []error{err}
+}
 
-	default:
-		// case reflect.Map:
-		// case reflect.Complex64:
-		// case reflect.Complex128:
-		// case reflect.Chan:
-		// case reflect.Func:
-		return []error{fmt.Errorf("%s: %v", ErrInvalidKind, v.Kind())}
+func setValueFromSel(v *reflect.Value, sel *goquery.Selection, vp *valuePlan, field string, pos position) *FieldError {
+
+	p := vp.path
+
+	if p.pseudo != nil {
+		return setValueFromText(v, sel, vp, field, p.pseudo(sel, pos))
 	}
 
+	s, err := p.accessor.extract(sel)
+	if err != nil {
+		return newFieldError(field, vp, sel, "accessor", "", err)
+	}
+
+	return setValueFromText(v, sel, vp, field, s)
+
 }
 
-func setValueFromSel(v *reflect.Value, sel *goquery.Selection, p *path) error {
+// setValueFromText runs s through the parse funcs of vp and sets
+// the result on v.
+func setValueFromText(v *reflect.Value, sel *goquery.Selection, vp *valuePlan, field, s string) *FieldError {
 
-	s, err := extractString(sel, p.acc)
+	s, err := parseText(sel, vp, field, s)
 	if err != nil {
 		return err
 	}
 
-	for _, pp := range p.parsers {
+	return setValueFromString(v, sel, vp, field, s)
+
+}
+
+// parseText runs s through the parse funcs of vp.
+func parseText(sel *goquery.Selection, vp *valuePlan, field, s string) (string, *FieldError) {
+	var err error
+	for _, pp := range vp.path.parsers {
+		in := s
 		s, err = pp.parse(s)
 		if err != nil {
-			return fmt.Errorf("%s: (parser fail) %q", p.selector, err)
+			return "", newFieldError(field, vp, sel, pp.name, in, err)
 		}
 	}
+	return s, nil
+}
+
+// setValueFromString loads or converts s into v.  The value is
+// built aside so a failure leaves v, and any nil pointers leading
+// to it, untouched.
+func setValueFromString(v *reflect.Value, sel *goquery.Selection, vp *valuePlan, field, s string) *FieldError {
+
+	tv := reflect.New(vp.typ).Elem()
+	if err := convertString(&tv, sel, vp, field, s); err != nil {
+		return err
+	}
+
+	resolvePointer(v)
+	v.Set(tv)
+	return nil
+
+}
+
+// isGoFloat reports whether v is a float and s is a float in Go
+// syntax, ie. 1e5 or NaN, which strconv reads whatever the locale.
+func isGoFloat(v *reflect.Value, s string) bool {
+	if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, v.Type().Bits())
+	return err == nil
+}
+
+func convertString(v *reflect.Value, sel *goquery.Selection, vp *valuePlan, field, s string) *FieldError {
 
-	if p.loader != nil {
-		vv, err := p.loader.load(sel, s)
+	if vp.loader != nil {
+		vv, err := vp.loader.load(sel, s)
 		if err != nil {
-			return fmt.Errorf("%s: (loader fail) %q", p.selector, err)
+			return newFieldError(field, vp, sel, vp.loader.name, s, err)
 		}
 		rv := reflect.ValueOf(vv)
 		// deref pointers for correct setting.
@@ -218,15 +771,31 @@ func setValueFromSel(v *reflect.Value, sel *goquery.Selection, p *path) error {
 		for rv.Kind() == reflect.Ptr {
 			rv = rv.Elem()
 		}
-		v.Set(rv)
-		return nil
+		if rv.IsValid() && rv.Type().AssignableTo(v.Type()) {
+			v.Set(rv)
+			return nil
+		}
+		// loaders emitting text for other types,
+		// ie. number(), have it converted below.
+		if rv.Kind() != reflect.String || !fromText(v.Type()) {
+			return newFieldError(field, vp, sel, vp.loader.name, s, fmt.Errorf("%w: %T loaded for %v", ErrInvalidKind, vv, v.Type()))
+		}
+		s = rv.String()
+	} else if vp.locale != nil {
+		n, err := vp.locale.parseNumber(s)
+		switch {
+		case err == nil:
+			s = n
+		case !isGoFloat(v, s):
+			return newFieldError(field, vp, sel, "conversion", s, err)
+		}
 	}
 
 	switch v.Kind() {
 	case reflect.Bool:
 		b, err := strconv.ParseBool(s)
 		if err != nil {
-			return fmt.Errorf("%s: %s", p.selector, err)
+			return newFieldError(field, vp, sel, "conversion", s, err)
 		}
 		v.SetBool(b)
 	case reflect.Int,
@@ -236,7 +805,7 @@ func setValueFromSel(v *reflect.Value, sel *goquery.Selection, p *path) error {
 		reflect.Int64:
 		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
 		if err != nil {
-			return fmt.Errorf("%s: %s", p.selector, err)
+			return newFieldError(field, vp, sel, "conversion", s, err)
 		}
 		v.SetInt(n)
 	case reflect.Uint,
@@ -247,33 +816,30 @@ func setValueFromSel(v *reflect.Value, sel *goquery.Selection, p *path) error {
 		reflect.Uintptr:
 		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
 		if err != nil {
-			return fmt.Errorf("%s: %s", p.selector, err)
+			return newFieldError(field, vp, sel, "conversion", s, err)
 		}
 		v.SetUint(n)
 	case reflect.Float32,
 		reflect.Float64:
 		n, err := strconv.ParseFloat(s, v.Type().Bits())
 		if err != nil {
-			return fmt.Errorf("%s: %s", p.selector, err)
+			return newFieldError(field, vp, sel, "conversion", s, err)
 		}
 		v.SetFloat(n)
 	case reflect.String:
 		v.SetString(s)
 	case reflect.Interface:
 		v.Set(reflect.ValueOf(s))
+	case reflect.Slice:
+		// []byte
+		v.SetBytes([]byte(s))
+	case reflect.Array:
+		// [N]byte
+		reflect.Copy(*v, reflect.ValueOf([]byte(s)))
 	default:
-		// unable to set these values from a string
-		// but should never reach this block with
-		// one of these value kinds.
-		// case reflect.Slice:
-		// case reflect.Struct:
-		// case reflect.Array:
-		// case reflect.Map:
-		// case reflect.Complex64:
-		// case reflect.Complex128:
-		// case reflect.Chan:
-		// case reflect.Func:
-		panic("unreachable")
+		// structs, maps, complex numbers, chans and
+		// funcs cannot be set from a string.
+		return newFieldError(field, vp, sel, "conversion", s, fmt.Errorf("%w: %v", ErrInvalidKind, v.Kind()))
 	}
 
 	return nil
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/emptyinterface/sq/test"
@@ -108,36 +111,35 @@ func TestText(t *testing.T) {
 	)
 
 	var expectederrs = []string{
-		`invalid kind: map`,
-		`p.int: strconv.ParseBool: parsing "-48": invalid syntax`,
-		`p.bool: strconv.ParseInt: parsing "true": invalid syntax`,
-		`p.bool: strconv.ParseUint: parsing "true": invalid syntax`,
-		`p.bool: strconv.ParseFloat: parsing "true": invalid syntax`,
-		`p.bool: (loader fail) "parsing time \"true\": extra text: true"`,
-		`attribute not found: attr(missing)`,
-		`attribute not found: attr(missing)`,
-		`attribute not found: attr(missing)`,
-		`Bad tag: "sq:\"derp(\\d)\""`,
-		`p.bool: (parser fail) "parse fail"`,
-		`p.bool: (loader fail) "load fail"`,
-		`div: (parser fail) "nested fail"`,
-		`div: (parser fail) "nested fail"`,
-		`private field with sq tag: "privatetagged"`,
-		`"blink" did not match`,
-		`"blink.selection" did not match`,
-		`"blink.node" did not match`,
-		`"blink.javascript" did not match`,
-		`"blink.css" did not match`,
-		`Bad accessor: "badacc.goquery"`,
-		`Bad accessor: "badacc.node"`,
-		`Bad accessor: "badacc.url"`,
-		`Bad accessor: "badacc.javascript"`,
-		`Bad accessor: "badacc.css"`,
-		`a: (parser fail) "parse fail"`,
-		`a: (parser fail) "parse fail"`,
-		`a: (parser fail) "parse fail"`,
-		`a: (parser fail) "parse fail"`,
-		`a: (parser fail) "parse fail"`,
+		`BadBool: p.int: (conversion) strconv.ParseBool: parsing "-48": invalid syntax`,
+		`BadInt: p.bool: (conversion) strconv.ParseInt: parsing "true": invalid syntax`,
+		`BadUint: p.bool: (conversion) strconv.ParseUint: parsing "true": invalid syntax`,
+		`BadFloat: p.bool: (conversion) strconv.ParseFloat: parsing "true": invalid syntax`,
+		`BadTime: p.bool: (time) unknown time format: "true"`,
+		`BadSlice: div: (accessor) attribute not found: attr(missing)`,
+		`BadArray: div: (accessor) attribute not found: attr(missing)`,
+		`BadAttr: div: (accessor) attribute not found: attr(missing)`,
+		`BadTag: Bad tag: "sq:\"derp(\\d)\""`,
+		`BadParse: p.bool: (parsefail) parse fail`,
+		`BadLoad: p.bool: (loadfail) load fail`,
+		`BadSliceofStructs[0].Field: div: (nestedfail) nested fail`,
+		`BadArrayofStructs[0].Field: div: (nestedfail) nested fail`,
+		`privatetagged: private field with sq tag: "privatetagged"`,
+		`Missing: blink: (select) node not found`,
+		`MissingSelection: blink.selection: (select) node not found`,
+		`MissingNode: blink.node: (select) node not found`,
+		`MissingJavascript: blink.javascript: (select) node not found`,
+		`MissingStylesheet: blink.css: (select) node not found`,
+		`BadAccSelection: Bad accessor: "badacc.goquery"`,
+		`BadAccNode: Bad accessor: "badacc.node"`,
+		`BadAccURL: Bad accessor: "badacc.url"`,
+		`BadAccJavascript: Bad accessor: "badacc.javascript"`,
+		`BadAccStylesheet: Bad accessor: "badacc.css"`,
+		`BadParserSelection: a: (parsefail) parse fail`,
+		`BadParserNode: a: (parsefail) parse fail`,
+		`BadParserURL: a: (parsefail) parse fail`,
+		`BadParserJavascript: a: (parsefail) parse fail`,
+		`BadParserStylesheet: a: (parsefail) parse fail`,
 	}
 
 	var tt test.TextType
@@ -318,3 +320,961 @@ func TestText(t *testing.T) {
 	}
 
 }
+
+func TestScraperIsolation(t *testing.T) {
+
+	const testHTML = `<p class="price">$12</p>`
+
+	type page struct {
+		Price string `sq:"p.price | text | price"`
+	}
+
+	a := New(WithParseFunc("price", func(s, _ string) (string, error) {
+		return strings.TrimPrefix(s, "$"), nil
+	}))
+	b := New()
+	b.RegisterParseFunc("price", func(s, _ string) (string, error) {
+		return s + " USD", nil
+	})
+
+	var pa, pb page
+	if errs := a.Scrape(&pa, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Error(errs)
+	}
+	if errs := b.Scrape(&pb, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Error(errs)
+	}
+	if pa.Price != "12" {
+		t.Errorf("Expected %q, got %q", "12", pa.Price)
+	}
+	if pb.Price != "$12 USD" {
+		t.Errorf("Expected %q, got %q", "$12 USD", pb.Price)
+	}
+
+	// neither registration leaks into the default scraper
+	var pd page
+	errs := Scrape(&pd, strings.NewReader(testHTML))
+	if len(errs) != 1 || !errors.Is(errs[0], ErrUnknownFunc) {
+		t.Errorf("Expected %q, got %q", ErrUnknownFunc, errs)
+	}
+
+}
+
+func TestScraperConcurrent(t *testing.T) {
+
+	const testHTML = `<p>1</p>`
+
+	s := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			s.RegisterParseFunc(fmt.Sprintf("f%d", i), func(s, _ string) (string, error) { return s, nil })
+		}(i)
+		go func() {
+			defer wg.Done()
+			var v struct {
+				N int `sq:"p | text"`
+			}
+			if errs := s.Scrape(&v, strings.NewReader(testHTML)); len(errs) > 0 {
+				t.Error(errs)
+			}
+		}()
+	}
+	wg.Wait()
+
+}
+
+func TestFieldError(t *testing.T) {
+
+	const testHTML = `
+		<table>
+			<tr><td>1</td><td><a href="mailto:a@example.com">a</a></td></tr>
+			<tr><td>2</td><td><a>b</a></td></tr>
+			<tr><td>3</td><td><a href="b@example.com">c</a></td></tr>
+		</table>
+	`
+
+	var page struct {
+		Users []struct {
+			ID    int    `sq:"td:nth-child(1) | text"`
+			Email string `sq:"td:nth-child(2) a | attr(href) | regexp(mailto:(.+))"`
+			Badge string `sq:"span.badge | text"`
+		} `sq:"tr"`
+	}
+
+	errs := Scrape(&page, strings.NewReader(testHTML))
+
+	expected := []struct {
+		path, selector, stage, input string
+		err                          error
+	}{
+		{"Users[0].Badge", "span.badge", "select", "", ErrNodeNotFound},
+		{"Users[1].Email", "td:nth-child(2) a", "accessor", "", ErrAttributeNotFound},
+		{"Users[1].Badge", "span.badge", "select", "", ErrNodeNotFound},
+		{"Users[2].Email", "td:nth-child(2) a", "regexp", "b@example.com", ErrNoRegexpMatch},
+		{"Users[2].Badge", "span.badge", "select", "", ErrNodeNotFound},
+	}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %q", len(expected), errs)
+	}
+	for i, err := range errs {
+		exp := expected[i]
+		var fe *FieldError
+		if !errors.As(err, &fe) {
+			t.Errorf("Expected *FieldError, got %T", err)
+			continue
+		}
+		if fe.Path != exp.path || fe.Selector != exp.selector || fe.Stage != exp.stage || fe.Input != exp.input {
+			t.Errorf("Expected %q %q %q %q, got %q %q %q %q", exp.path, exp.selector, exp.stage, exp.input, fe.Path, fe.Selector, fe.Stage, fe.Input)
+		}
+		if !errors.Is(err, exp.err) {
+			t.Errorf("Expected %q to wrap %q", err, exp.err)
+		}
+	}
+
+}
+
+func TestMap(t *testing.T) {
+
+	const testHTML = `
+		<html>
+			<head>
+				<meta name="description" content="A page">
+				<meta name="author" content="Someone">
+			</head>
+			<body>
+				<dl>
+					<dt>Weight</dt><dd>12 kg</dd>
+					<dt>Height</dt><dd>30 cm</dd>
+					<dt>Depth</dt><dd>n/a</dd>
+				</dl>
+				<table>
+					<tr><td>1</td><td>one</td></tr>
+					<tr><td>2</td><td>two</td></tr>
+					<tr><td>x</td><td>bad</td></tr>
+				</table>
+			</body>
+		</html>
+	`
+
+	var page struct {
+		Specs map[string]int    `sq:"dl dt, key(. | text), value(+ dd | text | regexp(\\d+))"`
+		Rows  map[int]string    `sq:"table tr, key(td:nth-child(1) | text), value(td:nth-child(2) | text)"`
+		Meta  map[string]string `sq:"meta[name], key(. | attr(name)), value(. | attr(content))"`
+	}
+
+	// key and value errors are both at the index of the entry
+	errs := Scrape(&page, strings.NewReader(testHTML))
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %q", errs)
+	}
+	var fe *FieldError
+	if !errors.As(errs[0], &fe) || fe.Path != "Specs[2]" || fe.Stage != "regexp" {
+		t.Errorf("Expected regexp error at Specs[2], got %q", errs[0])
+	}
+	if node := "html > body:nth-child(2) > dl:nth-child(1) > dd:nth-child(6)"; fe.Node != node {
+		t.Errorf("Expected node %q, got %q", node, fe.Node)
+	}
+	if !errors.As(errs[1], &fe) || fe.Path != "Rows[2]" || fe.Stage != "conversion" {
+		t.Errorf("Expected conversion error at Rows[2], got %q", errs[1])
+	}
+	if node := "html > body:nth-child(2) > table:nth-child(2) > tbody:nth-child(1) > tr:nth-child(3) > td:nth-child(1)"; fe.Node != node {
+		t.Errorf("Expected node %q, got %q", node, fe.Node)
+	}
+
+	if !reflect.DeepEqual(page.Specs, map[string]int{"Weight": 12, "Height": 30}) {
+		t.Errorf("Unexpected specs %v", page.Specs)
+	}
+	if !reflect.DeepEqual(page.Rows, map[int]string{1: "one", 2: "two"}) {
+		t.Errorf("Unexpected rows %v", page.Rows)
+	}
+	if !reflect.DeepEqual(page.Meta, map[string]string{"description": "A page", "author": "Someone"}) {
+		t.Errorf("Unexpected meta %v", page.Meta)
+	}
+
+}
+
+func TestAttrMaps(t *testing.T) {
+
+	const testHTML = `
+		<div id="p1" class="product" data-product-id="42" data-price="9.5" data-in-stock="true" data-x-Y="z">Widget</div>
+	`
+
+	var page struct {
+		Attrs   map[string]string `sq:"div.product"`
+		Data    map[string]string `sq:"div.product | dataset"`
+		Numbers map[string]int    `sq:"div.product | dataset | regexp(\\d+)"`
+		Product struct {
+			ProductID int
+			Price     float64
+			InStock   *bool
+			Missing   string
+		} `sq:"div.product | dataset"`
+		Text string `sq:"div.product | dataset"`
+	}
+
+	errs := Scrape(&page, strings.NewReader(testHTML))
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %q", errs)
+	}
+	for _, err := range errs {
+		var fe *FieldError
+		if !errors.As(err, &fe) || !strings.HasPrefix(fe.Path, "Numbers[") || fe.Stage != "regexp" {
+			t.Errorf("Expected regexp error in Numbers, got %q", err)
+		}
+	}
+
+	attrs := map[string]string{
+		"id": "p1", "class": "product", "data-product-id": "42",
+		"data-price": "9.5", "data-in-stock": "true", "data-x-y": "z",
+	}
+	if !reflect.DeepEqual(page.Attrs, attrs) {
+		t.Errorf("Expected %v, got %v", attrs, page.Attrs)
+	}
+	data := map[string]string{"productId": "42", "price": "9.5", "inStock": "true", "xY": "z"}
+	if !reflect.DeepEqual(page.Data, data) {
+		t.Errorf("Expected %v, got %v", data, page.Data)
+	}
+	if !reflect.DeepEqual(page.Numbers, map[string]int{"productId": 42, "price": 9}) {
+		t.Errorf("Unexpected numbers %v", page.Numbers)
+	}
+	if p := page.Product; p.ProductID != 42 || p.Price != 9.5 || p.InStock == nil || !*p.InStock || p.Missing != "" {
+		t.Errorf("Unexpected product %+v", p)
+	}
+	if expected := `productId="42" price="9.5" inStock="true" xY="z"`; page.Text != expected {
+		t.Errorf("Expected %q, got %q", expected, page.Text)
+	}
+
+	var bad struct {
+		Nested struct {
+			Inner struct{ A string }
+		} `sq:"div | attrs"`
+	}
+	if errs := Validate(bad); len(errs) != 1 || !errors.Is(errs[0], ErrBadTag) {
+		t.Errorf("Expected %q, got %q", ErrBadTag, errs)
+	}
+
+	var kinds struct {
+		Complex map[string]complex64 `sq:"div.product"`
+	}
+	if errs := Scrape(&kinds, strings.NewReader(testHTML)); len(errs) != 1 || !errors.Is(errs[0], ErrInvalidKind) {
+		t.Errorf("Expected %q, got %q", ErrInvalidKind, errs)
+	}
+
+}
+
+func TestAlternatives(t *testing.T) {
+
+	const testHTML = `
+		<div class="a"><span class="price">$12</span></div>
+		<div class="b"><meta itemprop="price" content="15"><span class="price">n/a</span></div>
+	`
+
+	type product struct {
+		Price int `sq:"span.price | text | regexp(\\d+) || [itemprop=price] | attr(content)"`
+	}
+
+	var page struct {
+		Products []product `sq:"div"`
+		Tags     []string  `sq:"blink | text || div | attr(class)"`
+		Missing  string    `sq:"blink | text || marquee | text, default(none)"`
+		Optional *int      `sq:"blink | text || div | attr(x), optional"`
+		Required string    `sq:"blink | text || div | attr(x), required"`
+		Failed   int       `sq:"div.b span | text || div.b span | text | regexp(\\d+)"`
+		Bytes    []byte    `sq:"blink | text || span.price | text"`
+		Nested   *struct {
+			P string `sq:"span | text"`
+		} `sq:"blink || div.b"`
+	}
+
+	errs := Scrape(&page, strings.NewReader(testHTML))
+	if len(errs) != 4 {
+		t.Fatalf("Expected 4 errors, got %q", errs)
+	}
+	for i, expected := range []struct {
+		stage string
+		err   error
+	}{
+		{"select", ErrRequired},
+		{"accessor", ErrRequired},
+		{"conversion", strconv.ErrSyntax},
+		{"regexp", ErrNoRegexpMatch},
+	} {
+		var fe *FieldError
+		if !errors.As(errs[i], &fe) || fe.Stage != expected.stage || !errors.Is(fe, expected.err) {
+			t.Errorf("Expected %s %q, got %q", expected.stage, expected.err, errs[i])
+		}
+	}
+
+	if len(page.Products) != 2 || page.Products[0].Price != 12 || page.Products[1].Price != 15 {
+		t.Errorf("Unexpected products %+v", page.Products)
+	}
+	if !reflect.DeepEqual(page.Tags, []string{"a", "b"}) {
+		t.Errorf("Unexpected tags %q", page.Tags)
+	}
+	if page.Missing != "none" || page.Optional != nil || page.Failed != 0 {
+		t.Errorf("Unexpected %q, %v and %d", page.Missing, page.Optional, page.Failed)
+	}
+	if string(page.Bytes) != "$12n/a" {
+		t.Errorf("Expected %q, got %q", "$12n/a", page.Bytes)
+	}
+	if page.Nested == nil || page.Nested.P != "n/a" {
+		t.Errorf("Unexpected nested %+v", page.Nested)
+	}
+
+}
+
+func TestAxes(t *testing.T) {
+
+	const testHTML = `
+		<html>
+			<head><title>Prices</title></head>
+			<body>
+				<h2>Fruit</h2>
+				<table data-currency="USD">
+					<tr><th>Name</th><th>Price</th></tr>
+					<tr class="item"><td>Apple</td><td>1</td></tr>
+					<tr class="item"><td>Pear</td><td>2</td></tr>
+				</table>
+				<p class="note">a</p>
+				<p class="note">b</p>
+				<h2>Veg</h2>
+				<p class="note">c</p>
+			</body>
+		</html>
+	`
+
+	var page struct {
+		Items []struct {
+			Name     string   `sq:"td:nth-child(1) | text"`
+			Title    string   `sq:"/ title | text"`
+			Header   string   `sq:"closest(table) th:nth-child(2) | text"`
+			Currency string   `sq:"closest(table) | attr(data-currency)"`
+			Prev     string   `sq:"prev(.item) td:nth-child(1) | text, optional"`
+			Next     string   `sq:"next() td:nth-child(1) | text, optional"`
+			Section  string   `sq:"closest(table) prev(h2) | text"`
+			Notes    []string `sq:"closest(table) nextuntil(h2, .note) | text"`
+		} `sq:"tr.item"`
+		Before []string `sq:"h2 + table ~ h2 | text"`
+	}
+
+	if errs := Scrape(&page, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	if len(page.Items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(page.Items))
+	}
+	apple, pear := page.Items[0], page.Items[1]
+	if apple.Name != "Apple" || apple.Title != "Prices" || apple.Header != "Price" || apple.Currency != "USD" {
+		t.Errorf("Unexpected item %+v", apple)
+	}
+	if apple.Prev != "" || apple.Next != "Pear" || pear.Prev != "Apple" || pear.Next != "" {
+		t.Errorf("Unexpected siblings %+v and %+v", apple, pear)
+	}
+	if apple.Section != "Fruit" || !reflect.DeepEqual(apple.Notes, []string{"a", "b"}) {
+		t.Errorf("Unexpected section %q and notes %q", apple.Section, apple.Notes)
+	}
+	if !reflect.DeepEqual(page.Before, []string{"Veg"}) {
+		t.Errorf("Unexpected %q", page.Before)
+	}
+
+}
+
+func TestXPath(t *testing.T) {
+
+	const testHTML = `
+		<html>
+			<head><title>Widget</title></head>
+			<body>
+				<table class="specs">
+					<tr><th>Weight</th><td>12 kg</td></tr>
+					<tr><th>Price</th><td>$15</td></tr>
+				</table>
+				<p>before <b>bold</b> after</p>
+				<a href="/a">A</a><a href="/b">B</a>
+			</body>
+		</html>
+	`
+
+	var page struct {
+		Title  string   `sq:"xpath://title | text"`
+		Price  int      `sq:"xpath://th[normalize-space(.)='Price']/following-sibling::td[1] | text | regexp(\\d+)"`
+		Own    []string `sq:"xpath://p/text() | text"`
+		Hrefs  []string `sq:"xpath:'//a/@href' | text"`
+		Either []string `sq:"xpath:'//th[1] | //b' | text"`
+		Rows   []struct {
+			Name  string `sq:"xpath:./th | text"`
+			Value string `sq:"td | text"`
+			Table string `sq:"xpath:ancestor::table | attr(class)"`
+		} `sq:"table tr"`
+	}
+
+	if errs := Scrape(&page, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	if page.Title != "Widget" || page.Price != 15 {
+		t.Errorf("Unexpected %q and %d", page.Title, page.Price)
+	}
+	if !reflect.DeepEqual(page.Own, []string{"before", "after"}) {
+		t.Errorf("Unexpected %q", page.Own)
+	}
+	if !reflect.DeepEqual(page.Hrefs, []string{"/a", "/b"}) {
+		t.Errorf("Unexpected %q", page.Hrefs)
+	}
+	if !reflect.DeepEqual(page.Either, []string{"Weight", "Price", "bold"}) {
+		t.Errorf("Unexpected %q", page.Either)
+	}
+	if len(page.Rows) != 2 || page.Rows[1].Name != "Price" || page.Rows[1].Value != "$15" || page.Rows[1].Table != "specs" {
+		t.Errorf("Unexpected rows %+v", page.Rows)
+	}
+
+	var bad struct {
+		X string `sq:"xpath:'//p[' | text"`
+	}
+	if errs := Validate(bad); len(errs) != 1 || !errors.Is(errs[0], ErrInvalidXPath) {
+		t.Errorf("Expected %q, got %q", ErrInvalidXPath, errs)
+	}
+
+}
+
+func TestPseudoFields(t *testing.T) {
+
+	const testHTML = `
+		<html><body>
+			<ul>
+				<li><span>a</span></li>
+				<li><span>b</span></li>
+				<li><span>c</span></li>
+			</ul>
+		</body></html>
+	`
+
+	type item struct {
+		Index int    `sq:"@index"`
+		Count int    `sq:"@count"`
+		Label string `sq:"@index | prepend(#)"`
+		Tag   string `sq:"@tag"`
+		Path  string `sq:"@path"`
+		Depth int    `sq:"@depth"`
+		Inner struct {
+			Index int    `sq:"@index"`
+			Tag   string `sq:"@tag"`
+		} `sq:"span"`
+	}
+
+	var page struct {
+		Items []item         `sq:"li"`
+		ByPos map[int]string `sq:"li span, key(@index), value(. | text)"`
+		Index int            `sq:"@index"`
+	}
+
+	if errs := Scrape(&page, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	if len(page.Items) != 3 {
+		t.Fatalf("Expected 3 items, got %d", len(page.Items))
+	}
+	second := page.Items[1]
+	if second.Index != 1 || second.Count != 3 || second.Label != "#1" || second.Tag != "li" || second.Depth != 3 {
+		t.Errorf("Unexpected item %+v", second)
+	}
+	if expected := "html > body:nth-child(2) > ul:nth-child(1) > li:nth-child(2)"; second.Path != expected {
+		t.Errorf("Expected %q, got %q", expected, second.Path)
+	}
+	if second.Inner.Index != 1 || second.Inner.Tag != "span" {
+		t.Errorf("Unexpected inner %+v", second.Inner)
+	}
+	if !reflect.DeepEqual(page.ByPos, map[int]string{0: "a", 1: "b", 2: "c"}) {
+		t.Errorf("Unexpected %v", page.ByPos)
+	}
+
+	var bad struct {
+		Unknown string   `sq:"@nope"`
+		Slice   []string `sq:"@tag"`
+	}
+	if errs := Validate(bad); len(errs) != 2 || !errors.Is(errs[0], ErrBadTag) || !errors.Is(errs[1], ErrBadTag) {
+		t.Errorf("Expected %q, got %q", ErrBadTag, errs)
+	}
+
+}
+
+func TestSliceOptions(t *testing.T) {
+
+	const testHTML = `
+		<table>
+			<tr><th>Name</th><th>Price</th></tr>
+			<tr><td>a</td><td>1</td></tr>
+			<tr class="ad"><td>ad</td><td>0</td></tr>
+			<tr><td>b</td><td>n/a</td></tr>
+			<tr><td>a</td><td>3</td></tr>
+			<tr><td>c</td><td>4</td></tr>
+			<tr><td>d</td><td>5</td></tr>
+		</table>
+	`
+
+	type row struct {
+		Name  string `sq:"td:nth-child(1) | text"`
+		Price int    `sq:"td:nth-child(2) | text, required"`
+	}
+
+	var page struct {
+		Rows    []row    `sq:"tr, where(td), skipinvalid"`
+		Unique  []row    `sq:"tr:not(.ad), offset(1), skipinvalid, unique(Name)"`
+		Limited []*row   `sq:"tr, where(td:first-child:contains(a)), offset(1), limit(2)"`
+		Names   []string `sq:"td:nth-child(1) | text, unique"`
+	}
+
+	if errs := Scrape(&page, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	names := func(rows []row) string {
+		var s []string
+		for _, r := range rows {
+			s = append(s, r.Name)
+		}
+		return strings.Join(s, ",")
+	}
+	if s := names(page.Rows); s != "a,ad,a,c,d" {
+		t.Errorf("Expected rows a,ad,a,c,d, got %s", s)
+	}
+	if s := names(page.Unique); s != "a,c,d" {
+		t.Errorf("Expected unique rows a,c,d, got %s", s)
+	}
+	if len(page.Limited) != 2 || page.Limited[0].Name != "ad" || page.Limited[1].Price != 3 {
+		t.Errorf("Unexpected limited rows %+v", page.Limited)
+	}
+	if s := strings.Join(page.Names, ","); s != "a,ad,b,c,d" {
+		t.Errorf("Expected names a,ad,b,c,d, got %s", s)
+	}
+
+	// without skipinvalid errors are reported at the element's
+	// index in the resulting slice
+	var errPage struct {
+		Rows []row `sq:"tr, where(td), offset(1)"`
+	}
+	errs := Scrape(&errPage, strings.NewReader(testHTML))
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "Rows[1].Price:") {
+		t.Errorf("Expected a Rows[1].Price error, got %q", errs)
+	}
+
+	// skipinvalid only drops elements whose required fields
+	// fail, others are kept with their errors
+	type optionalRow struct {
+		Name  string `sq:"td:nth-child(1) | text"`
+		Price int    `sq:"td:nth-child(2) | text, optional"`
+	}
+	var optionalPage struct {
+		Rows []optionalRow `sq:"tr, where(td), skipinvalid"`
+	}
+	errs = Scrape(&optionalPage, strings.NewReader(testHTML))
+	if len(optionalPage.Rows) != 6 || optionalPage.Rows[2].Name != "b" {
+		t.Errorf("Unexpected rows %+v", optionalPage.Rows)
+	}
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "Rows[2].Price:") {
+		t.Errorf("Expected a Rows[2].Price error, got %q", errs)
+	}
+
+	// duplicates still report their errors, elements without
+	// a key are never duplicates
+	const itemsHTML = `
+		<ul>
+			<li><b>x</b><i>1</i></li>
+			<li><b>x</b><i>n/a</i></li>
+			<li><i>2</i></li>
+			<li><i>3</i></li>
+		</ul>
+	`
+	type item struct {
+		Name *string `sq:"b | text, optional"`
+		N    int     `sq:"i | text"`
+	}
+	var items struct {
+		Items []item `sq:"li, unique(Name)"`
+	}
+	errs = Scrape(&items, strings.NewReader(itemsHTML))
+	if len(items.Items) != 3 || items.Items[0].N != 1 || items.Items[2].N != 3 {
+		t.Errorf("Unexpected items %+v", items.Items)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], strconv.ErrSyntax) {
+		t.Errorf("Expected %q, got %q", strconv.ErrSyntax, errs)
+	}
+
+	var bad struct {
+		NotSlice string   `sq:"p | text, limit(1)"`
+		Limit    []string `sq:"p | text, limit(0)"`
+		Offset   []string `sq:"p | text, offset(x)"`
+		Where    []string `sq:"p | text, where(:imatches(x{2,1}))"`
+		Field    []row    `sq:"tr, unique(Nope)"`
+		Elem     []string `sq:"p | text, unique(Name)"`
+	}
+	expected := []string{
+		"NotSlice: Bad tag: limit(), offset(), where(), skipinvalid, unique() and group() only apply to slice fields",
+		"Limit: Bad tag: limit(0) requires a positive count",
+		"Offset: Bad tag: offset(x) requires a positive count",
+		"Where: :imatches(x{2,1}): invalid regexp: error parsing regexp: invalid repeat count: `{2,1}`",
+		`Field: Bad tag: unique(Nope): sq.row has no field "Nope"`,
+		"Elem: Bad tag: unique(Name) requires struct elements, not string",
+	}
+	errs = Validate(bad)
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %q, got %q", expected, errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], err)
+		}
+	}
+
+}
+
+func TestGroups(t *testing.T) {
+
+	const testHTML = `
+		<article>
+			<p>preamble</p>
+			<h2>One</h2>
+			<p>a</p>
+			<p>b <a href="/b">link</a></p>
+			<h2 class="ad">Ad</h2>
+			<p>buy</p>
+			<h2>Two</h2>
+			<p>c</p>
+		</article>
+		<article>
+			<h2>Three</h2>
+		</article>
+	`
+
+	type section struct {
+		Title string   `sq:"h2 | text"`
+		Paras []string `sq:"p | text, optional"`
+		Links []string `sq:"a | attr(href), optional"`
+		Index int      `sq:"@index"`
+	}
+
+	var page struct {
+		Sections []section `sq:"article, group(h2), where(h2:not(.ad))"`
+		Texts    []string  `sq:"article | innertext, group(h2), limit(2)"`
+	}
+
+	if errs := Scrape(&page, strings.NewReader(testHTML)); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	expected := []section{
+		{Title: "One", Paras: []string{"a", "b link"}, Links: []string{"/b"}, Index: 0},
+		{Title: "Two", Paras: []string{"c"}, Index: 1},
+	}
+	if len(page.Sections) != 3 {
+		t.Fatalf("Expected 3 sections, got %+v", page.Sections)
+	}
+	for i, exp := range expected {
+		got := page.Sections[i]
+		if got.Title != exp.Title || got.Index != exp.Index ||
+			strings.Join(got.Paras, ",") != strings.Join(exp.Paras, ",") ||
+			strings.Join(got.Links, ",") != strings.Join(exp.Links, ",") {
+			t.Errorf("Expected %+v, got %+v", exp, got)
+		}
+	}
+	if got := page.Sections[2]; got.Title != "Three" || len(got.Paras) != 0 {
+		t.Errorf("Unexpected section %+v", got)
+	}
+	if !reflect.DeepEqual(page.Texts, []string{"One\na\nb link", "Ad\nbuy"}) {
+		t.Errorf("Unexpected texts %q", page.Texts)
+	}
+
+	var bad struct {
+		Empty []string `sq:"article | text, group()"`
+		XPath []string `sq:"article | text, group(xpath://h2)"`
+	}
+	if errs := Validate(bad); len(errs) != 2 || !errors.Is(errs[0], ErrBadTag) || !errors.Is(errs[1], ErrBadTag) {
+		t.Errorf("Expected %q, got %q", ErrBadTag, errs)
+	}
+
+}
+
+func TestOptional(t *testing.T) {
+
+	const testHTML = `
+		<div class="product">
+			<span class="price">12</span>
+			<span class="rating">n/a</span>
+			<a href="/p/1">link</a>
+		</div>
+	`
+
+	var page struct {
+		Price    int       `sq:"span.price | text, required"`
+		Discount int       `sq:"span.discount | text, optional"`
+		Badge    string    `sq:"span.badge | text, default(none)"`
+		Stock    int       `sq:"a | attr(data-stock), default(0)"`
+		Title    string    `sq:"a | attr(title), optional"`
+		Tags     []string  `sq:"span.tag | text, optional"`
+		Added    time.Time `sq:"span.added | text | time(2006 01 02), default(2016 05 23)"`
+		Rating   float64   `sq:"span.rating | text, optional"`
+		SKU      string    `sq:"span.sku | text, required"`
+	}
+	page.Discount = 5
+
+	errs := Scrape(&page, strings.NewReader(testHTML))
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %q", errs)
+	}
+
+	// present but unparsable is still an error
+	if !errors.As(errs[0], new(*FieldError)) || errors.Is(errs[0], ErrNodeNotFound) {
+		t.Errorf("Expected conversion error, got %q", errs[0])
+	}
+	// missing required fields are distinct
+	if !errors.Is(errs[1], ErrRequired) || !errors.Is(errs[1], ErrNodeNotFound) {
+		t.Errorf("Expected %q, got %q", ErrRequired, errs[1])
+	}
+
+	if page.Price != 12 {
+		t.Errorf("Expected %d, got %d", 12, page.Price)
+	}
+	if page.Discount != 0 {
+		t.Errorf("Expected %d, got %d", 0, page.Discount)
+	}
+	if page.Badge != "none" {
+		t.Errorf("Expected %q, got %q", "none", page.Badge)
+	}
+	if page.Stock != 0 || page.Title != "" || page.Tags != nil {
+		t.Errorf("Expected zero values, got %v %q %v", page.Stock, page.Title, page.Tags)
+	}
+	if page.Added.Year() != 2016 {
+		t.Errorf("Expected %d, got %d", 2016, page.Added.Year())
+	}
+
+	// defaults of loaders are only loaded when scraping, not
+	// checked with a made up selection while compiling
+	var loads int
+	s := New()
+	s.RegisterLoadFunc("host", func(sel *goquery.Selection, s, _ string) (interface{}, error) {
+		loads++
+		if strings.ContainsAny(s, " ") {
+			return nil, fmt.Errorf("bad host %q", s)
+		}
+		return s, nil
+	})
+	var hosts struct {
+		Host string `sq:"a.host | text | host, default(localhost)"`
+		Bad  string `sq:"a.host | text | host, default(no host)"`
+	}
+	if errs := s.Validate(&hosts); len(errs) > 0 || loads > 0 {
+		t.Errorf("Expected no errors or loads, got %q and %d loads", errs, loads)
+	}
+	errs = s.Scrape(&hosts, strings.NewReader(testHTML))
+	if hosts.Host != "localhost" {
+		t.Errorf("Expected %q, got %q", "localhost", hosts.Host)
+	}
+	var fe *FieldError
+	if len(errs) != 1 || !errors.As(errs[0], &fe) || fe.Path != "Bad" || fe.Stage != "host" {
+		t.Errorf("Expected a Bad host error, got %q", errs)
+	}
+
+	// bad tags are reported rather than their defaults loaded
+	var badDefault struct {
+		Struct struct{ A string } `sq:"blink, default(3)"`
+		Kind   complex64          `sq:"blink, optional"`
+	}
+	errs = Scrape(&badDefault, strings.NewReader(testHTML))
+	if len(errs) != 2 || !errors.Is(errs[0], ErrBadTag) || !errors.Is(errs[1], ErrInvalidKind) {
+		t.Errorf("Expected tag errors, got %q", errs)
+	}
+
+}
+
+func TestPointers(t *testing.T) {
+
+	const testHTML = `
+		<table>
+			<tr><td>1</td><td>2</td><td>3</td></tr>
+		</table>
+		<p class="count">many</p>
+	`
+
+	type page struct {
+		Row     *test.Row  `sq:"tr"`
+		Missing *test.Row  `sq:"blink"`
+		Time    *time.Time `sq:"p.time | text | time(2006), optional"`
+		Count   *int       `sq:"p.count | text"`
+		Absent  *int       `sq:"p.absent | text, default(7)"`
+	}
+
+	var p page
+	errs := Scrape(&p, strings.NewReader(testHTML))
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %q", errs)
+	}
+	if p.Row == nil || p.Row.String2 != "2" {
+		t.Errorf("Expected row to be set, got %+v", p.Row)
+	}
+	if p.Missing != nil || p.Time != nil || p.Count != nil {
+		t.Errorf("Expected nil pointers, got %v %v %v", p.Missing, p.Time, p.Count)
+	}
+	if p.Absent == nil || *p.Absent != 7 {
+		t.Errorf("Expected default to be set, got %v", p.Absent)
+	}
+
+	var eager page
+	New(WithEagerPointers()).Scrape(&eager, strings.NewReader(testHTML))
+	if eager.Missing == nil || eager.Time == nil || eager.Count == nil {
+		t.Errorf("Expected allocated pointers, got %v %v %v", eager.Missing, eager.Time, eager.Count)
+	}
+
+}
+
+type (
+	money struct {
+		Currency string
+		Cents    int64
+	}
+	sku string
+)
+
+func (m *money) UnmarshalSQ(sel *goquery.Selection, text string) error {
+	m.Currency, _ = sel.Attr("data-currency")
+	f, err := strconv.ParseFloat(strings.TrimPrefix(text, "$"), 64)
+	m.Cents = int64(math.Round(f * 100))
+	return err
+}
+
+func (s *sku) UnmarshalText(text []byte) error {
+	if !bytes.HasPrefix(text, []byte("SKU-")) {
+		return errors.New("not a sku")
+	}
+	*s = sku(bytes.TrimPrefix(text, []byte("SKU-")))
+	return nil
+}
+
+func TestUnmarshalers(t *testing.T) {
+
+	const testHTML = `
+		<span class="price" data-currency="USD">$19.99</span>
+		<ul><li>SKU-1</li><li>SKU-2</li><li>3</li></ul>
+	`
+
+	var page struct {
+		Price    money  `sq:"span.price | text"`
+		PricePtr *money `sq:"span.price | text"`
+		SKUs     []sku  `sq:"li | text"`
+	}
+
+	errs := Scrape(&page, strings.NewReader(testHTML))
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %q", errs)
+	}
+	var fe *FieldError
+	if !errors.As(errs[0], &fe) || fe.Path != "SKUs[2]" || fe.Stage != "UnmarshalText" {
+		t.Errorf("Expected UnmarshalText error at SKUs[2], got %q", errs[0])
+	}
+
+	expected := money{Currency: "USD", Cents: 1999}
+	if page.Price != expected {
+		t.Errorf("Expected %v, got %v", expected, page.Price)
+	}
+	if page.PricePtr == nil || *page.PricePtr != expected {
+		t.Errorf("Expected %v, got %v", expected, page.PricePtr)
+	}
+	if !reflect.DeepEqual(page.SKUs, []sku{"1", "2", ""}) {
+		t.Errorf("Unexpected skus %q", page.SKUs)
+	}
+
+}
+
+func TestGeneric(t *testing.T) {
+
+	const testHTML = `
+		<html>
+			<head><title>Users</title></head>
+			<body>
+				<div class="user"><b>alice</b><i>30</i></div>
+				<div class="user"><b>bob</b><i>x</i></div>
+			</body>
+		</html>
+	`
+
+	type user struct {
+		Name string `sq:"b | text"`
+		Age  int    `sq:"i | text"`
+	}
+	type page struct {
+		Title string `sq:"title | text"`
+		Users []user `sq:"div.user"`
+	}
+
+	p, err := ScrapeAs[page](strings.NewReader(testHTML))
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Path != "Users[1].Age" {
+		t.Errorf("Expected error at Users[1].Age, got %v", err)
+	}
+	if p.Title != "Users" || len(p.Users) != 2 || p.Users[0].Age != 30 {
+		t.Errorf("Unexpected page %+v", p)
+	}
+
+	pp, err := ScrapeAs[*page](strings.NewReader(testHTML))
+	if pp == nil || pp.Title != "Users" || err == nil {
+		t.Errorf("Unexpected page %+v, %v", pp, err)
+	}
+
+	users, err := ScrapeAll[user](strings.NewReader(testHTML), "div.user")
+	if !errors.As(err, &fe) || fe.Path != "[1].Age" {
+		t.Errorf("Expected error at [1].Age, got %v", err)
+	}
+	if len(users) != 2 || users[0].Name != "alice" || users[1].Name != "bob" {
+		t.Errorf("Unexpected users %+v", users)
+	}
+
+	users, _ = ScrapeAll[user](strings.NewReader(testHTML), "xpath://div[@class='user']")
+	if len(users) != 2 || users[1].Name != "bob" {
+		t.Errorf("Unexpected users %+v", users)
+	}
+	if _, err := ScrapeAll[user](strings.NewReader(testHTML), "p["); !errors.Is(err, ErrInvalidSelector) {
+		t.Errorf("Expected %q, got %v", ErrInvalidSelector, err)
+	}
+	if _, err := ScrapeAll[user](strings.NewReader(testHTML), "xpath://p["); !errors.Is(err, ErrInvalidXPath) {
+		t.Errorf("Expected %q, got %v", ErrInvalidXPath, err)
+	}
+
+	type shout struct {
+		Name string `sq:"b | text | shout"`
+	}
+	s := New()
+	s.RegisterParseFunc("shout", func(s, _ string) (string, error) { return strings.ToUpper(s), nil })
+	if v, err := ScrapeAsWith[shout](s, strings.NewReader(testHTML)); err != nil || v.Name != "ALICEBOB" {
+		t.Errorf("Unexpected %+v, %v", v, err)
+	}
+	if vs, err := ScrapeAllWith[shout](s, strings.NewReader(testHTML), "div.user"); err != nil || len(vs) != 2 || vs[1].Name != "BOB" {
+		t.Errorf("Unexpected %+v, %v", vs, err)
+	}
+
+	if _, err := ScrapeAs[string](strings.NewReader(testHTML)); !errors.Is(err, ErrNonStructPtrValue) {
+		t.Errorf("Expected %q, got %v", ErrNonStructPtrValue, err)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromDoc, fromSel, fromNode user
+	ScrapeFrom(&fromDoc, doc)
+	ScrapeFrom(&fromSel, doc.Find("div.user").Last())
+	ScrapeFrom(&fromNode, doc.Find("div.user").Get(0))
+	if fromDoc.Name != "alicebob" || fromSel.Name != "bob" || fromNode.Name != "alice" {
+		t.Errorf("Unexpected users %+v %+v %+v", fromDoc, fromSel, fromNode)
+	}
+	var fromWith shout
+	if errs := ScrapeFromWith(s, &fromWith, doc.Find("div.user").First()); len(errs) > 0 || fromWith.Name != "ALICE" {
+		t.Errorf("Unexpected %+v, %q", fromWith, errs)
+	}
+
+}
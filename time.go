@@ -0,0 +1,260 @@
+package sq
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var ErrUnknownTimeFormat = errors.New("unknown time format")
+
+// timeParser parses the times of the time loaders.  Times without a
+// zone are read in location, and month and day names in the language
+// of locale.
+type timeParser struct {
+	location *time.Location
+	locale   *locale
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// timeFormats are the layouts tried when none is given, after Unix
+// times.
+var timeFormats = []string{
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// unixTime matches Unix times in seconds, with an optional fraction,
+// or in milli, micro or nanoseconds.
+var unixTime = regexp.MustCompile(`^-?(\d{9,})(?:\.(\d+))?$`)
+
+// timeLoader returns the load func of the time.Time type loader,
+// which detects the format of times.
+func timeLoader(tp *timeParser) func(sel *goquery.Selection, s string) (interface{}, error) {
+	return func(_ *goquery.Selection, s string) (interface{}, error) {
+		return tp.parse(s, "")
+	}
+}
+
+// load parses s with the layouts separated by "|", in order, or
+// detects its format if there are none.
+func (tp *timeParser) load(_ *goquery.Selection, s, layouts string) (interface{}, error) {
+	return tp.parse(s, layouts)
+}
+
+func (tp *timeParser) parse(s, layouts string) (time.Time, error) {
+
+	s = strings.TrimSpace(s)
+	loc := tp.location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	// names shared by a month and its abbreviation,
+	// ie. French juin, may be either in the layout
+	inputs := []string{s}
+	if tp.locale != nil {
+		lang, _, _ := strings.Cut(tp.locale.name, "-")
+		full, abbr := translateDateNames(s, lang, false), translateDateNames(s, lang, true)
+		inputs = []string{full}
+		if abbr != full {
+			inputs = append(inputs, abbr)
+		}
+	}
+
+	var first error
+	for _, in := range inputs {
+		if strings.TrimSpace(layouts) == "" {
+			if t, err := detectTime(in, loc); err == nil {
+				return t, nil
+			} else if first == nil {
+				first = err
+			}
+			continue
+		}
+		for _, layout := range strings.Split(layouts, "|") {
+			t, err := time.ParseInLocation(strings.TrimSpace(layout), in, loc)
+			if err == nil {
+				return t, nil
+			}
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return time.Time{}, first
+
+}
+
+// detectTime parses s as a Unix time, RFC 3339 or RFC 1123 time.
+func detectTime(s string, loc *time.Location) (time.Time, error) {
+
+	if m := unixTime.FindStringSubmatch(s); m != nil {
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if strings.HasPrefix(s, "-") {
+			n = -n
+		}
+		var t time.Time
+		switch {
+		case len(m[1]) <= 11:
+			frac, _ := strconv.ParseFloat("0."+m[2], 64)
+			if n < 0 {
+				frac = -frac
+			}
+			t = time.Unix(n, int64(frac*1e9))
+		case m[2] != "":
+			return time.Time{}, fmt.Errorf("%w: %q", ErrUnknownTimeFormat, s)
+		case len(m[1]) <= 14:
+			t = time.UnixMilli(n)
+		case len(m[1]) <= 17:
+			t = time.UnixMicro(n)
+		default:
+			t = time.Unix(0, n)
+		}
+		return t.In(loc), nil
+	}
+
+	for _, layout := range timeFormats {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: %q", ErrUnknownTimeFormat, s)
+
+}
+
+func checkLayouts(layouts string) error {
+	if strings.TrimSpace(layouts) == "" {
+		return nil
+	}
+	for _, layout := range strings.Split(layouts, "|") {
+		if strings.TrimSpace(layout) == "" {
+			return fmt.Errorf("%w: empty layout", ErrBadTag)
+		}
+	}
+	return nil
+}
+
+// dateNames are the month and day names of a language, January and
+// Sunday first.
+type dateNames struct {
+	months, monthAbbrs [12]string
+	days, dayAbbrs     [7]string
+}
+
+// languageDateNames are keyed by lower case language.
+var languageDateNames = map[string]dateNames{
+	"de": {
+		months:     [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		monthAbbrs: [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+		days:       [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		dayAbbrs:   [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+	},
+	"fr": {
+		months:     [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		monthAbbrs: [12]string{"janv", "févr", "mars", "avr", "mai", "juin", "juil", "août", "sept", "oct", "nov", "déc"},
+		days:       [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		dayAbbrs:   [7]string{"dim", "lun", "mar", "mer", "jeu", "ven", "sam"},
+	},
+	"es": {
+		months:     [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		monthAbbrs: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sep", "oct", "nov", "dic"},
+		days:       [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		dayAbbrs:   [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+	},
+	"it": {
+		months:     [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		monthAbbrs: [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		days:       [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		dayAbbrs:   [7]string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+	},
+	"nl": {
+		months:     [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		monthAbbrs: [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		days:       [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		dayAbbrs:   [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+	},
+	"pt": {
+		months:     [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		monthAbbrs: [12]string{"jan", "fev", "mar", "abr", "mai", "jun", "jul", "ago", "set", "out", "nov", "dez"},
+		days:       [7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		dayAbbrs:   [7]string{"dom", "seg", "ter", "qua", "qui", "sex", "sáb"},
+	},
+}
+
+// translateDateNames replaces the month and day names of lang in s
+// with English ones.  Names that are also abbreviations are written
+// abbreviated if abbr is set.  Month names win over day abbreviations
+// they collide with, ie. Spanish mar.
+func translateDateNames(s, lang string, abbr bool) string {
+
+	names, exists := languageDateNames[lang]
+	if !exists {
+		return s
+	}
+
+	english := map[string]string{}
+	add := func(name, en string) {
+		english[strings.ToLower(name)] = en
+	}
+	for i, d := range names.dayAbbrs {
+		add(d, time.Weekday(i).String()[:3])
+	}
+	for i, d := range names.days {
+		add(d, time.Weekday(i).String())
+	}
+	for i, m := range names.monthAbbrs {
+		add(m, time.Month(i + 1).String()[:3])
+	}
+	for i, m := range names.months {
+		if abbr && strings.EqualFold(m, names.monthAbbrs[i]) {
+			continue
+		}
+		add(m, time.Month(i+1).String())
+	}
+
+	var b strings.Builder
+	for len(s) > 0 {
+		i := strings.IndexFunc(s, unicode.IsLetter)
+		if i == -1 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+		end := len(s)
+		for j, r := range s {
+			// hyphenated words, ie. segunda-feira
+			if next, _ := utf8.DecodeRuneInString(s[j+1:]); !unicode.IsLetter(r) && (r != '-' || !unicode.IsLetter(next)) {
+				end = j
+				break
+			}
+		}
+		word := s[:end]
+		if en, exists := english[strings.ToLower(word)]; exists {
+			word = en
+		}
+		b.WriteString(word)
+		s = s[end:]
+	}
+	return b.String()
+
+}